@@ -0,0 +1,173 @@
+// Command composebench compares the single-pass composer with the former
+// per-part composer on a generated deck, reporting wall time and how far the
+// audio and video streams drift from the narration.
+//
+//	go run ./cmd/composebench -slides 50 -segments 3
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"log"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LeonRhapsody/pptTovideo/internal/audio"
+	"github.com/LeonRhapsody/pptTovideo/internal/config"
+	"github.com/LeonRhapsody/pptTovideo/internal/video"
+)
+
+func main() {
+	slides := flag.Int("slides", 50, "number of slides in the fixture")
+	segments := flag.Int("segments", 3, "narration segments per slide")
+	subtitles := flag.Bool("subtitles", true, "burn in subtitles")
+	dir := flag.String("dir", "", "work directory (default: a temp dir)")
+	keep := flag.Bool("keep", false, "keep the fixture and outputs")
+	profile := flag.String("profile", config.DefaultEncodingProfile, "encoding profile")
+	flag.Parse()
+
+	// Both composers and the fixture need the tools; fail before any work.
+	for _, tool := range []string{"ffmpeg", "ffprobe"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			log.Fatalf("composebench needs %s on PATH: %v", tool, err)
+		}
+	}
+
+	workDir := *dir
+	if workDir == "" {
+		var err error
+		if workDir, err = os.MkdirTemp("", "composebench-"); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if !*keep {
+		defer os.RemoveAll(workDir)
+	}
+
+	images, audios, texts, expected, err := buildFixture(workDir, *slides, *segments)
+	if err != nil {
+		log.Fatalf("building fixture: %v", err)
+	}
+	fmt.Printf("Fixture: %d slides, %d segments, %.3fs of narration in %s\n", *slides, len(audios), expected, workDir)
+
+	encoding, ok := config.LoadConfig().EncodingProfile(*profile)
+	if !ok {
+		log.Fatalf("unknown encoding profile %q", *profile)
+	}
+	opts := video.RenderOptions{EnableSubtitles: *subtitles, SubtitleStyle: config.BuiltinSubtitlePresets[0].Style, Encoding: encoding}
+	composers := []struct {
+		name    string
+		compose func([]string, []string, []string, string, video.RenderOptions) error
+	}{
+		{"per-part", composeParts},
+		{"single-pass", video.ComposeVideo},
+	}
+
+	var elapsed []time.Duration
+	for _, c := range composers {
+		out := filepath.Join(workDir, c.name+".mp4")
+		start := time.Now()
+		if err := c.compose(images, audios, texts, out, opts); err != nil {
+			log.Fatalf("%s: %v", c.name, err)
+		}
+		took := time.Since(start)
+		elapsed = append(elapsed, took)
+
+		videoDur, audioDur, err := streamDurations(out)
+		if err != nil {
+			log.Fatalf("%s: probing output: %v", c.name, err)
+		}
+		fmt.Printf("%-12s %8.2fs  video %.3fs (%+.3fs)  audio %.3fs (%+.3fs)  A/V %+.3fs\n",
+			c.name, took.Seconds(),
+			videoDur, videoDur-expected,
+			audioDur, audioDur-expected,
+			videoDur-audioDur)
+	}
+	fmt.Printf("Speedup: %.1fx\n", elapsed[0].Seconds()/elapsed[1].Seconds())
+}
+
+// buildFixture writes one image per slide and a tone of random length per
+// segment, and returns the inputs of ComposeVideo with the total narration.
+func buildFixture(dir string, slides, segments int) ([]string, []string, []string, float64, error) {
+	rng := rand.New(rand.NewSource(1))
+	var images, audios, texts []string
+	total := 0.0
+
+	for s := 0; s < slides; s++ {
+		img := filepath.Join(dir, fmt.Sprintf("slide-%02d.jpg", s+1))
+		if err := writeSlide(img, s); err != nil {
+			return nil, nil, nil, 0, err
+		}
+
+		for k := 0; k < segments; k++ {
+			dur := 1.5 + rng.Float64()*4
+			wav := filepath.Join(dir, fmt.Sprintf("audio_%d_%d.wav", s, k))
+			cmd := exec.Command("ffmpeg", "-hide_banner", "-nostdin",
+				"-f", "lavfi", "-i", fmt.Sprintf("sine=frequency=%d:sample_rate=%d:duration=%.3f", 220+40*k, audio.SampleRate, dur),
+				"-y", wav)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				return nil, nil, nil, 0, fmt.Errorf("%w: %s", err, out)
+			}
+			d, err := audio.FileDuration(wav)
+			if err != nil {
+				return nil, nil, nil, 0, err
+			}
+			total += d
+
+			images = append(images, img)
+			audios = append(audios, wav)
+			texts = append(texts, fmt.Sprintf("第 %d 页第 %d 句 Slide %d sentence %d", s+1, k+1, s+1, k+1))
+		}
+	}
+	return images, audios, texts, total, nil
+}
+
+func writeSlide(path string, index int) error {
+	img := image.NewRGBA(image.Rect(0, 0, 1920, 1080))
+	c := color.RGBA{uint8(40 + index*37%200), uint8(60 + index*53%180), uint8(90 + index*71%160), 255}
+	draw.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return jpeg.Encode(f, img, nil)
+}
+
+// streamDurations returns the durations of the video and audio streams.
+func streamDurations(path string) (float64, float64, error) {
+	out, err := exec.Command("ffprobe", "-v", "error",
+		"-show_entries", "stream=codec_type,duration", "-of", "csv=p=0", path).Output()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var videoDur, audioDur float64
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Split(line, ",")
+		if len(fields) != 2 {
+			continue
+		}
+		d, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			continue
+		}
+		switch fields[0] {
+		case "video":
+			videoDur = d
+		case "audio":
+			audioDur = d
+		}
+	}
+	return videoDur, audioDur, nil
+}
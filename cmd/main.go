@@ -41,6 +41,22 @@ func main() {
 		apiGroup.POST("/parse", handler.HandleParse)
 		apiGroup.POST("/preview", handler.HandlePreview)
 		apiGroup.POST("/render", handler.HandleRender)
+		apiGroup.POST("/music", handler.HandleUploadMusic)
+		apiGroup.POST("/narration", handler.HandleUploadNarration)
+		apiGroup.POST("/branding", handler.HandleUploadBranding)
+		apiGroup.POST("/presenter", handler.HandleUploadPresenter)
+		apiGroup.GET("/voices/fishspeech", handler.HandleListVoices)
+		apiGroup.POST("/voices/fishspeech", handler.HandleUploadVoice)
+		apiGroup.GET("/voices/fishspeech/:id/audio", handler.HandleVoiceAudio)
+		apiGroup.DELETE("/voices/fishspeech/:id", handler.HandleDeleteVoice)
+		apiGroup.GET("/engines", handler.HandleListEngines)
+		apiGroup.GET("/profiles", handler.HandleListProfiles)
+		apiGroup.GET("/fonts", handler.HandleListFonts)
+		apiGroup.POST("/fonts", handler.HandleUploadFont)
+		apiGroup.GET("/subtitle-presets", handler.HandleListSubtitlePresets)
+		apiGroup.POST("/subtitle-presets", handler.HandleSaveSubtitlePreset)
+		apiGroup.DELETE("/subtitle-presets/:name", handler.HandleDeleteSubtitlePreset)
+		apiGroup.GET("/usage", handler.HandleGetUsage)
 		apiGroup.GET("/tasks", handler.HandleGetTasks)
 		apiGroup.GET("/config", handler.HandleGetConfig)
 		apiGroup.POST("/config", handler.HandleSaveConfig)
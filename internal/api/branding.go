@@ -0,0 +1,115 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/LeonRhapsody/pptTovideo/internal/audio"
+	"github.com/LeonRhapsody/pptTovideo/internal/video"
+	"github.com/gin-gonic/gin"
+)
+
+// brandingDir holds the intro, outro and watermark uploaded for a job.
+const brandingDir = "branding"
+
+var (
+	clipExtensions  = []string{".mp4", ".mov", ".m4v", ".webm", ".mkv"}
+	imageExtensions = []string{".png", ".jpg", ".jpeg", ".webp"}
+)
+
+// Branding selects uploaded branding files by the names returned by
+// /api/branding. Zero values fall back to the defaults of video.Watermark.
+type Branding struct {
+	Intro     string `json:"intro"`
+	Outro     string `json:"outro"`
+	Watermark string `json:"watermark"`
+
+	WatermarkPosition string  `json:"watermark_position"` // "top_left", ..., "center"; default "top_right"
+	WatermarkOpacity  float64 `json:"watermark_opacity"`  // 0 to 1
+	WatermarkMargin   int     `json:"watermark_margin"`   // Pixels
+	WatermarkWidth    float64 `json:"watermark_width"`    // Relative to the video width
+
+	FadeIn  float64 `json:"fade_in"` // Seconds
+	FadeOut float64 `json:"fade_out"`
+}
+
+// resolve checks the branding of a render and returns it for the composer,
+// with file names resolved in the job's work dir.
+func (b *Branding) resolve(workDir string) (*video.Branding, error) {
+	if !video.ValidPosition(b.WatermarkPosition) {
+		return nil, fmt.Errorf("unknown watermark position %q", b.WatermarkPosition)
+	}
+
+	var err error
+	out := &video.Branding{FadeIn: b.FadeIn, FadeOut: b.FadeOut}
+	if b.Intro != "" {
+		if out.Intro, err = jobUploadPath(workDir, brandingDir, b.Intro); err != nil {
+			return nil, err
+		}
+	}
+	if b.Outro != "" {
+		if out.Outro, err = jobUploadPath(workDir, brandingDir, b.Outro); err != nil {
+			return nil, err
+		}
+	}
+	if b.Watermark != "" {
+		path, err := jobUploadPath(workDir, brandingDir, b.Watermark)
+		if err != nil {
+			return nil, err
+		}
+		out.Watermark = &video.Watermark{
+			Path:     path,
+			Position: b.WatermarkPosition,
+			Opacity:  b.WatermarkOpacity,
+			Margin:   b.WatermarkMargin,
+			Width:    b.WatermarkWidth,
+		}
+	}
+	return out, nil
+}
+
+// HandleUploadBranding stores an intro or outro clip or a watermark image for
+// a job. The form field "kind" says which.
+func (h *Handler) HandleUploadBranding(c *gin.Context) {
+	workDir, ok := jobWorkDir(c.PostForm("job_id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job expired or not found"})
+		return
+	}
+
+	kind := c.PostForm("kind")
+	var allowed []string
+	switch kind {
+	case "intro", "outro":
+		allowed = clipExtensions
+	case "watermark":
+		allowed = imageExtensions
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "kind must be intro, outro or watermark"})
+		return
+	}
+
+	name, err := saveJobUploadAs(c, workDir, brandingDir, allowed, kind)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	path := filepath.Join(workDir, brandingDir, name)
+	response := gin.H{
+		"file": name,
+		"url":  "/" + filepath.ToSlash(path),
+	}
+	if kind != "watermark" {
+		duration, err := audio.Duration(path)
+		if err != nil || duration <= 0 {
+			os.Remove(path)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Uploaded file is not a readable video"})
+			return
+		}
+		response["duration"] = duration
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/LeonRhapsody/pptTovideo/internal/audio"
+	"github.com/LeonRhapsody/pptTovideo/internal/video"
+)
+
+// chaptersFile is written to the work dir of each render with the chapters
+// muxed into the video.
+const chaptersFile = "chapters.json"
+
+// maxChapterTitle is the length in runes that chapter titles are cut to.
+const maxChapterTitle = 60
+
+// chapterTitle returns the title of the chapter of a slide: the title of the
+// slide, or else the first line of its notes.
+func chapterTitle(slide SlideData, speakers map[string]VoiceSettings) string {
+	title := strings.Join(strings.Fields(slide.Title), " ")
+	if title == "" {
+		notes, _ := extractFocus(slide.Text)
+		for _, line := range strings.Split(stripCallouts(notes), "\n") {
+			if m := speakerTagPattern.FindStringSubmatchIndex(line); m != nil {
+				if _, ok := speakers[line[m[2]:m[3]]]; ok {
+					line = line[m[1]:]
+				}
+			}
+			if line = strings.TrimSpace(strings.ReplaceAll(line, pauseMarker, " ")); line != "" {
+				title = line
+				break
+			}
+		}
+	}
+	if title == "" {
+		return fmt.Sprintf("Slide %d", slide.Index+1)
+	}
+	if runes := []rune(title); len(runes) > maxChapterTitle {
+		title = string(runes[:maxChapterTitle-1]) + "…"
+	}
+	return title
+}
+
+// buildChapters returns one chapter per slide of the rendered segments, timed
+// by their audio. The intro and outro clips of branding are added to the
+// first and last chapter, so that the chapters cover the whole video.
+func buildChapters(req RenderRequest, segments []renderSegment, branding *video.Branding) ([]video.Chapter, error) {
+	intro, outro, err := branding.ClipDurations()
+	if err != nil {
+		return nil, err
+	}
+
+	titles := make(map[int]string)
+	for _, slide := range req.Slides {
+		titles[slide.Index] = chapterTitle(slide, req.Speakers)
+	}
+
+	var chapters []video.Chapter
+	elapsed := intro
+	for i, seg := range segments {
+		d, err := audio.FileDuration(seg.AudioPath)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 || seg.Slide != segments[i-1].Slide {
+			start := elapsed
+			if i == 0 {
+				start = 0
+			}
+			chapters = append(chapters, video.Chapter{Title: titles[seg.Slide], Start: start})
+		}
+		elapsed += d
+		chapters[len(chapters)-1].End = elapsed
+	}
+	if n := len(chapters); n > 0 {
+		chapters[n-1].End += outro
+	}
+	return chapters, nil
+}
+
+func writeChapters(path string, chapters []video.Chapter) error {
+	data, err := json.MarshalIndent(chapters, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func readChapters(path string) ([]video.Chapter, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var chapters []video.Chapter
+	err = json.Unmarshal(data, &chapters)
+	return chapters, err
+}
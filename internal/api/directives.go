@@ -0,0 +1,84 @@
+package api
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/LeonRhapsody/pptTovideo/internal/video"
+)
+
+// focusPattern matches a "[focus:x,y,w,h]" directive in notes, which makes the
+// camera zoom towards that region of the slide while it is shown. Coordinates
+// are normalized to the slide.
+var focusPattern = regexp.MustCompile(`\[focus:\s*([\d.]+)\s*,\s*([\d.]+)\s*,\s*([\d.]+)\s*,\s*([\d.]+)\s*\]`)
+
+// calloutPattern matches a "[highlight:x,y,w,h]" or "[zoom:x,y,w,h]"
+// directive in notes, which points out a region of the slide while the
+// sentence containing it is spoken.
+var calloutPattern = regexp.MustCompile(`[ \t]*\[(highlight|zoom):\s*([\d.]+)\s*,\s*([\d.]+)\s*,\s*([\d.]+)\s*,\s*([\d.]+)\s*\]`)
+
+// calloutMarkerPattern matches the markers that stand in for callout
+// directives while notes are split into sentences. Unlike the directives,
+// markers contain no sentence delimiters.
+var calloutMarkerPattern = regexp.MustCompile("\x00(\\d+)\x00")
+
+// parseRegion parses the four coordinates captured by a directive pattern.
+func parseRegion(values []string) (video.Region, bool) {
+	var v [4]float64
+	for i, s := range values {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return video.Region{}, false
+		}
+		v[i] = f
+	}
+	r := video.Region{X: v[0], Y: v[1], W: v[2], H: v[3]}
+	return r, r.Valid()
+}
+
+// extractFocus removes focus directives from notes and returns the motion of
+// the last valid one, or nil if there is none.
+func extractFocus(text string) (string, *video.Motion) {
+	var motion *video.Motion
+	for _, m := range focusPattern.FindAllStringSubmatch(text, -1) {
+		if r, ok := parseRegion(m[1:]); ok {
+			motion = &video.Motion{Effect: video.MotionFocus, Region: &r}
+		}
+	}
+	return strings.TrimSpace(focusPattern.ReplaceAllString(text, "")), motion
+}
+
+// markCallouts replaces the callout directives in notes with markers and
+// returns the callouts they refer to. Invalid directives are removed.
+func markCallouts(text string) (string, []*video.Callout) {
+	var callouts []*video.Callout
+	text = calloutPattern.ReplaceAllStringFunc(text, func(directive string) string {
+		m := calloutPattern.FindStringSubmatch(directive)
+		r, ok := parseRegion(m[2:])
+		if !ok {
+			return ""
+		}
+		callouts = append(callouts, &video.Callout{Effect: m[1], Region: r})
+		return fmt.Sprintf("\x00%d\x00", len(callouts)-1)
+	})
+	return text, callouts
+}
+
+// takeCallout removes the callout markers from a sentence and returns the
+// callout of the last one, or nil if it has none.
+func takeCallout(sentence string, callouts []*video.Callout) (string, *video.Callout) {
+	var callout *video.Callout
+	for _, m := range calloutMarkerPattern.FindAllStringSubmatch(sentence, -1) {
+		if i, err := strconv.Atoi(m[1]); err == nil && i < len(callouts) {
+			callout = callouts[i]
+		}
+	}
+	return strings.TrimSpace(calloutMarkerPattern.ReplaceAllString(sentence, "")), callout
+}
+
+// stripCallouts removes callout directives from notes.
+func stripCallouts(text string) string {
+	return strings.TrimSpace(calloutPattern.ReplaceAllString(text, ""))
+}
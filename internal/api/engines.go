@@ -0,0 +1,102 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+
+	"github.com/LeonRhapsody/pptTovideo/internal/config"
+	"github.com/LeonRhapsody/pptTovideo/internal/tts"
+	"github.com/gin-gonic/gin"
+)
+
+// EngineResponse describes a selectable TTS engine.
+type EngineResponse struct {
+	Engine string   `json:"engine"`
+	Label  string   `json:"label"`
+	Custom bool     `json:"custom"`
+	Voices []string `json:"voices,omitempty"`
+}
+
+// HandleListEngines lists the built-in engines followed by the custom engines
+// from config. Custom engines shadowed by a built-in name are left out.
+func (h *Handler) HandleListEngines(c *gin.Context) {
+	builtin := make(map[string]bool)
+	custom := h.Config.Engines()
+	engines := make([]EngineResponse, 0, len(tts.BuiltinEngines)+len(custom))
+	for _, e := range tts.BuiltinEngines {
+		builtin[string(e)] = true
+		engines = append(engines, EngineResponse{Engine: string(e), Label: string(e)})
+	}
+
+	for _, e := range custom {
+		if builtin[e.Name] {
+			continue
+		}
+		label := e.Label
+		if label == "" {
+			label = e.Name
+		}
+		engines = append(engines, EngineResponse{
+			Engine: e.Name,
+			Label:  label,
+			Custom: true,
+			Voices: e.Voices,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"engines": engines})
+}
+
+// validateCustomEngines checks that every engine can be created and has a
+// name of its own.
+func validateCustomEngines(engines []config.CustomEngine) error {
+	seen := make(map[string]bool)
+	for _, e := range engines {
+		if e.Name == "" {
+			return fmt.Errorf("custom engine needs a name")
+		}
+		if slices.Contains(tts.BuiltinEngines, tts.EngineType(e.Name)) {
+			return fmt.Errorf("custom engine %q has the name of a built-in engine", e.Name)
+		}
+		if seen[e.Name] {
+			return fmt.Errorf("custom engine %q is defined twice", e.Name)
+		}
+		seen[e.Name] = true
+		if _, err := tts.NewTemplateProvider(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateFallbacks checks that every fallback names a built-in engine or one
+// of custom.
+func validateFallbacks(fallbacks []config.TTSFallback, custom []config.CustomEngine) error {
+	for _, f := range fallbacks {
+		if f.Engine == "" {
+			return fmt.Errorf("fallback voice needs an engine")
+		}
+		if !slices.Contains(tts.BuiltinEngines, tts.EngineType(f.Engine)) &&
+			!slices.ContainsFunc(custom, func(e config.CustomEngine) bool { return e.Name == f.Engine }) {
+			return fmt.Errorf("fallback engine %q does not exist", f.Engine)
+		}
+	}
+	return nil
+}
+
+// checkCommandEngines refuses command engines sent over HTTP, since their
+// commands run on the server. Only those already defined in config.json may
+// come back unchanged, as the settings panel sends every engine it loaded.
+func checkCommandEngines(engines, current []config.CustomEngine) error {
+	for _, e := range engines {
+		if len(e.Command) == 0 {
+			continue
+		}
+		i := slices.IndexFunc(current, func(c config.CustomEngine) bool { return c.Name == e.Name })
+		if i < 0 || !reflect.DeepEqual(current[i], e) {
+			return fmt.Errorf("custom engine %q runs a command; command engines can only be defined in config.json", e.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/LeonRhapsody/pptTovideo/internal/video"
+	"github.com/gin-gonic/gin"
+)
+
+// HandleListFonts lists the font families a subtitle style can name.
+func (h *Handler) HandleListFonts(c *gin.Context) {
+	var families []string
+	for _, f := range video.ListFonts() {
+		if !slices.Contains(families, f.Family) {
+			families = append(families, f.Family)
+		}
+	}
+	slices.Sort(families)
+	c.JSON(http.StatusOK, gin.H{"families": families})
+}
+
+// HandleUploadFont stores a font file, such as a brand font, in the font dir
+// and makes it available to subtitles by its family name. The upload is
+// checked before it replaces a font of the same file name.
+func (h *Handler) HandleUploadFont(c *gin.Context) {
+	if err := os.MkdirAll(h.Config.FontDir, 0755); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	tmpDir, err := os.MkdirTemp(h.Config.FontDir, ".upload-")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer os.RemoveAll(tmpDir)
+
+	name, err := saveJobUploadAs(c, tmpDir, "", video.FontExtensions, "")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := video.CheckFont(filepath.Join(tmpDir, name)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Uploaded file is not a readable font"})
+		return
+	}
+
+	path := filepath.Join(h.Config.FontDir, name)
+	if err := os.Rename(filepath.Join(tmpDir, name), path); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store font: " + err.Error()})
+		return
+	}
+	fonts, err := video.RegisterFont(path)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"file": name, "fonts": fonts})
+}
@@ -6,23 +6,40 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/LeonRhapsody/pptTovideo/internal/audio"
 	"github.com/LeonRhapsody/pptTovideo/internal/config"
 	"github.com/LeonRhapsody/pptTovideo/internal/ppt"
 	"github.com/LeonRhapsody/pptTovideo/internal/tts"
+	"github.com/LeonRhapsody/pptTovideo/internal/usage"
 	"github.com/LeonRhapsody/pptTovideo/internal/video"
+	"github.com/LeonRhapsody/pptTovideo/internal/voices"
 	"github.com/gin-gonic/gin"
 )
 
 type Handler struct {
 	Config *config.Config
+	Voices *voices.Store
+	Usage  *usage.Store // nil if the usage file could not be loaded
 }
 
 func NewHandler(cfg *config.Config) *Handler {
-	return &Handler{Config: cfg}
+	store, err := usage.NewStore(cfg.UsageFile)
+	if err != nil {
+		// Don't start over with an empty store, that would overwrite the file.
+		fmt.Printf("Warning: TTS usage accounting disabled: %v\n", err)
+		store = nil
+	}
+	video.AddFontDir(cfg.FontDir)
+	return &Handler{
+		Config: cfg,
+		Voices: voices.NewStore(cfg.VoiceDir),
+		Usage:  store,
+	}
 }
 
 // -- Request/Response Structs --
@@ -30,7 +47,22 @@ func NewHandler(cfg *config.Config) *Handler {
 type SlideData struct {
 	Index    int    `json:"index"`
 	ImageURL string `json:"image_url"` // Relative URL
+	Title    string `json:"title,omitempty"`
 	Text     string `json:"text"`
+
+	// Voice optionally overrides the render-wide voice for this slide.
+	Voice *VoiceSettings `json:"voice,omitempty"`
+
+	// Narration names a recording uploaded through /api/narration that is
+	// used instead of TTS. Text still provides the subtitles.
+	Narration string `json:"narration,omitempty"`
+
+	// Motion overrides the render-wide camera motion for this slide.
+	Motion *video.Motion `json:"motion,omitempty"`
+
+	// Presenter names a recording uploaded through /api/presenter for this
+	// slide, shown picture-in-picture from the start of the slide.
+	Presenter string `json:"presenter,omitempty"`
 }
 
 type ParseResponse struct {
@@ -57,7 +89,67 @@ type RenderRequest struct {
 	Slides           []SlideData `json:"slides" binding:"required"`
 	EnableSubtitles  bool        `json:"enable_subtitles"`
 	SubtitleFontSize int         `json:"subtitle_font_size"`
-	Quality          string      `json:"quality"` // "720p", "1080p", "4k"
+	Quality          string      `json:"quality"` // Encoding profile, e.g. "720p", "1080p", "4k"
+
+	// SubtitleStyle sets how subtitles look; otherwise SubtitlePreset names a
+	// saved or built-in style, config.DefaultSubtitlePreset by default.
+	// SubtitleFontSize applies to styles without a font size.
+	SubtitleStyle  *config.SubtitleStyle `json:"subtitle_style"`
+	SubtitlePreset string                `json:"subtitle_preset"`
+
+	// Translation adds subtitles in a second language, drawn under the
+	// subtitles or as a separate track.
+	Translation *Translation `json:"translation"`
+
+	// TargetSizeMB encodes the video at the bitrate that makes it about this
+	// large, overriding the bitrate of the profile.
+	TargetSizeMB float64 `json:"target_size_mb"`
+
+	// Fallbacks overrides the configured fallback chain for this render.
+	Fallbacks []config.TTSFallback `json:"fallbacks"`
+
+	// Speakers maps speaker tags used in notes (e.g. "A" for "A: ...") to voices.
+	Speakers map[string]VoiceSettings `json:"speakers"`
+
+	// Loudness enables EBU R128 normalization of every segment when set.
+	// Unset fields default to audio.DefaultLoudnessTarget.
+	Loudness *audio.LoudnessTarget `json:"loudness"`
+
+	// Pacing controls trimming and pauses; defaultPacing applies when unset.
+	Pacing *Pacing `json:"pacing"`
+
+	// BackgroundMusic mixes a previously uploaded track under the narration.
+	BackgroundMusic *BackgroundMusic `json:"background_music"`
+
+	// Motion moves the camera over every slide, e.g. a slow zoom-in.
+	// A [focus:x,y,w,h] directive in the notes overrides it for a slide.
+	Motion *video.Motion `json:"motion"`
+
+	// Canvas sets the aspect or size of the video, e.g. 9:16 for short-video
+	// platforms, and how slides fill it. Unset keeps the slide's own size.
+	Canvas *video.Canvas `json:"canvas"`
+
+	// Branding adds intro and outro clips, a watermark and fades.
+	Branding *Branding `json:"branding"`
+
+	// Presenter overlays a recording of the presenter. Its placement also
+	// applies to the recordings of single slides.
+	Presenter *Presenter `json:"presenter"`
+
+	// Outputs lists extra formats converted from the MP4, see video.Formats.
+	// TeaserDuration is the length of the "gif" and "teaser" outputs in seconds.
+	Outputs        []string `json:"outputs"`
+	TeaserDuration float64  `json:"teaser_duration"`
+}
+
+// BackgroundMusic selects an uploaded music track and how it is mixed.
+// Zero values fall back to a quiet bed with short fades.
+type BackgroundMusic struct {
+	File           string  `json:"file"`    // Name returned by /api/music
+	Volume         float64 `json:"volume"`  // Linear gain, default 0.3
+	FadeIn         float64 `json:"fade_in"` // Seconds, default 2
+	FadeOut        float64 `json:"fade_out"`
+	DisableDucking bool    `json:"disable_ducking"`
 }
 
 // splitTextIntoSentences splits text based on punctuation.
@@ -157,6 +249,7 @@ func (h *Handler) HandleParse(c *gin.Context) {
 		responseSlides = append(responseSlides, SlideData{
 			Index:    i,
 			ImageURL: url,
+			Title:    slides[i].Title,
 			Text:     slides[i].Note,
 		})
 	}
@@ -174,7 +267,10 @@ func (h *Handler) HandlePreview(c *gin.Context) {
 		return
 	}
 
-	provider, err := tts.NewTTSProvider(tts.EngineType(req.EngineType), h.Config)
+	meter := func(engine tts.EngineType, text string, failed bool) {
+		h.recordUsage(previewJobID, engine, text, failed)
+	}
+	provider, err := tts.NewMeteredTTSProvider(tts.EngineType(req.EngineType), h.Config, h.Voices, meter)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid engine"})
 		return
@@ -200,6 +296,8 @@ func (h *Handler) HandlePreview(c *gin.Context) {
 	// is to use periods which its natural processing understands.
 	// However, we'll use a more explicit approach if needed.
 	processedText = strings.ReplaceAll(processedText, "[停顿]", "... ")
+	processedText, _ = extractFocus(processedText)
+	processedText = stripCallouts(processedText)
 
 	if err := provider.Synthesize(processedText, tmpFile.Name(), req.VoiceName, opts); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -222,6 +320,22 @@ func (h *Handler) HandleSaveConfig(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := validateCustomEngines(newCfg.CustomEngines); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := checkCommandEngines(newCfg.CustomEngines, h.Config.Engines()); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	engines := newCfg.CustomEngines
+	if engines == nil {
+		engines = h.Config.Engines()
+	}
+	if err := validateFallbacks(newCfg.TTSFallbacks, engines); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Update current config
 	// We need to be careful not to overwrite Port if it's not in JSON,
@@ -241,6 +355,18 @@ func (h *Handler) HandleSaveConfig(c *gin.Context) {
 	h.Config.OpenAIAPIKey = newCfg.OpenAIAPIKey
 	h.Config.OpenAIBaseURL = newCfg.OpenAIBaseURL
 
+	h.Config.FishSpeechAPIKey = newCfg.FishSpeechAPIKey
+	h.Config.FishSpeechAPIURL = newCfg.FishSpeechAPIURL
+
+	// Custom engines and fallbacks are only replaced by clients that send
+	// them, and take effect for the next render.
+	if newCfg.CustomEngines != nil {
+		h.Config.SetCustomEngines(newCfg.CustomEngines)
+	}
+	if newCfg.TTSFallbacks != nil {
+		h.Config.SetFallbacks(newCfg.TTSFallbacks)
+	}
+
 	if err := h.Config.Save(); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save config: " + err.Error()})
 		return
@@ -265,164 +391,97 @@ func (h *Handler) HandleRender(c *gin.Context) {
 		req.SubtitleFontSize = 48
 	}
 
-	workDir := filepath.Join("uploads", req.JobID)
-	if _, err := os.Stat(workDir); os.IsNotExist(err) {
+	workDir, ok := jobWorkDir(req.JobID)
+	if !ok {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Job expired or not found"})
 		return
 	}
 
-	jobID := req.JobID
-	GlobalJobManager.CreateJob(jobID)
-
-	c.JSON(http.StatusOK, gin.H{"job_id": jobID, "message": "Rendering started"})
+	if req.BackgroundMusic != nil {
+		if _, err := jobUploadPath(workDir, musicDir, req.BackgroundMusic.File); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
 
-	go func() {
-		GlobalJobManager.UpdateProgress(jobID, 10, "Initializing...")
+	if req.Quality == "" {
+		req.Quality = config.DefaultEncodingProfile
+	}
+	if _, ok := h.Config.EncodingProfile(req.Quality); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown encoding profile %q", req.Quality)})
+		return
+	}
 
-		provider, err := tts.NewTTSProvider(tts.EngineType(req.EngineType), h.Config)
-		if err != nil {
-			GlobalJobManager.FailJob(jobID, "Invalid TTS engine")
+	if req.Branding != nil {
+		if _, err := req.Branding.resolve(workDir); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+	}
 
-		// Quality mapping
-		dpi := 150
-		quality := strings.ToLower(req.Quality)
-		if quality == "" {
-			quality = "1080p"
-		}
+	if _, err := h.subtitleStyle(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-		switch quality {
-		case "720p":
-			dpi = 120
-		case "4k":
-			dpi = 300
-		default:
-			dpi = 150
-		}
+	if err := req.Translation.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Translation != nil && req.Translation.bilingual() && !req.EnableSubtitles {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Bilingual subtitles need subtitles enabled"})
+		return
+	}
 
-		// Re-generate images if DPI is different from default (150)
-		imageDir := filepath.Join(workDir, "images")
-		if dpi != 150 {
-			imageDir = filepath.Join(workDir, fmt.Sprintf("images_%d", dpi))
-			if _, err := os.Stat(imageDir); os.IsNotExist(err) {
-				GlobalJobManager.UpdateProgress(jobID, 12, fmt.Sprintf("Re-generating images for %s...", quality))
-				pptxPath := ""
-				// Find pptx file in workDir
-				files, _ := ioutil.ReadDir(workDir)
-				for _, f := range files {
-					if strings.HasSuffix(strings.ToLower(f.Name()), ".pptx") {
-						pptxPath = filepath.Join(workDir, f.Name())
-						break
-					}
-				}
-				if pptxPath != "" {
-					_, err := ppt.ConvertSlidesToImages(pptxPath, imageDir, dpi)
-					if err != nil {
-						GlobalJobManager.FailJob(jobID, "Failed to re-generate high-quality images: "+err.Error())
-						return
-					}
-				}
-			}
-		}
+	if err := req.Canvas.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-		audioDir := filepath.Join(workDir, "audio_render")
-		os.MkdirAll(audioDir, 0755)
+	for _, format := range req.Outputs {
+		if !slices.Contains(video.Formats, format) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unsupported output format %q", format)})
+			return
+		}
+	}
 
-		type Segment struct {
-			Text      string
-			AudioPath string
-			ImagePath string
+	if req.Presenter != nil {
+		if err := req.Presenter.settings("").Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
-		var segments []Segment
-
-		for _, slide := range req.Slides {
-			urlParts := strings.Split(slide.ImageURL, "/")
-			filename := urlParts[len(urlParts)-1]
-			// Use the potentially re-generated image directory
-			imgPath := filepath.Join(imageDir, filename)
-
-			rawText := slide.Text
-			if len(strings.TrimSpace(rawText)) == 0 {
-				segments = append(segments, Segment{
-					Text:      "",
-					ImagePath: imgPath,
-				})
-			} else {
-				if req.EnableSubtitles {
-					subSentences := splitTextIntoSentences(rawText)
-					if len(subSentences) == 0 {
-						subSentences = []string{rawText}
-					}
-					for _, s := range subSentences {
-						segments = append(segments, Segment{
-							Text:      s,
-							ImagePath: imgPath,
-						})
-					}
-				} else {
-					// Subtitles disabled: one segment per slide
-					segments = append(segments, Segment{
-						Text:      rawText,
-						ImagePath: imgPath,
-					})
-				}
+		if req.Presenter.File != "" {
+			if _, err := jobUploadPath(workDir, presenterDir, req.Presenter.File); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
 			}
 		}
-		totalSegments := len(segments)
-
-		for i, seg := range segments {
-			progress := 10 + int(float64(i)/float64(totalSegments)*70.0)
-			GlobalJobManager.UpdateProgress(jobID, progress, fmt.Sprintf("Synthesizing audio %d/%d", i+1, totalSegments))
-
-			outPath := filepath.Join(audioDir, fmt.Sprintf("audio_%d.mp3", i))
-
-			textToSpeak := seg.Text
-			if len(strings.TrimSpace(textToSpeak)) == 0 {
-				textToSpeak = "..."
-			}
-			textToSpeak = strings.ReplaceAll(textToSpeak, "[停顿]", "... ")
-
-			opts := tts.Options{
-				Rate:   req.Rate,
-				Volume: req.Volume,
-				Pitch:  req.Pitch,
-			}
+	}
 
-			if err := provider.Synthesize(textToSpeak, outPath, req.VoiceName, opts); err != nil {
-				errMsg := fmt.Sprintf("TTS failed for segment %d: %v", i+1, err)
-				GlobalJobManager.FailJob(jobID, errMsg)
+	for _, slide := range req.Slides {
+		if slide.Narration != "" {
+			if _, err := jobUploadPath(workDir, narrationDir, slide.Narration); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 				return
 			}
-
-			segments[i].AudioPath = outPath
 		}
-
-		var imagePaths []string
-		var audioPaths []string
-		var texts []string
-		for _, seg := range segments {
-			imagePaths = append(imagePaths, seg.ImagePath)
-			audioPaths = append(audioPaths, seg.AudioPath)
-			texts = append(texts, seg.Text)
+		if slide.Presenter != "" {
+			if _, err := jobUploadPath(workDir, presenterDir, slide.Presenter); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
 		}
+	}
 
-		GlobalJobManager.UpdateProgress(jobID, 85, "Rendering Video...")
-		outputVideoPath := filepath.Join(workDir, fmt.Sprintf("output_%d.mp4", time.Now().Unix()))
+	if err := h.checkQuotas(req, workDir); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
 
-		opts := video.RenderOptions{
-			EnableSubtitles: req.EnableSubtitles,
-			FontSize:        req.SubtitleFontSize,
-			VideoQuality:    quality,
-		}
+	jobID := req.JobID
+	GlobalJobManager.CreateJob(jobID)
 
-		if err := video.ComposeVideo(imagePaths, audioPaths, texts, outputVideoPath, opts); err != nil {
-			errMsg := fmt.Sprintf("Video composition failed: %v", err)
-			GlobalJobManager.FailJob(jobID, errMsg)
-			return
-		}
+	c.JSON(http.StatusOK, gin.H{"job_id": jobID, "message": "Rendering started"})
 
-		downloadURL := fmt.Sprintf("/uploads/%s/%s", req.JobID, filepath.Base(outputVideoPath))
-		GlobalJobManager.CompleteJob(jobID, downloadURL)
-	}()
+	go h.render(jobID, workDir, req)
 }
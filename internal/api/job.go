@@ -3,6 +3,9 @@ package api
 import (
 	"sync"
 	"time"
+
+	"github.com/LeonRhapsody/pptTovideo/internal/audio"
+	"github.com/LeonRhapsody/pptTovideo/internal/video"
 )
 
 type JobStatus string
@@ -22,6 +25,33 @@ type Job struct {
 	DownloadURL string    `json:"download_url,omitempty"`
 	CreatedAt   time.Time `json:"created_at"`
 	Error       string    `json:"error,omitempty"`
+
+	Segments []SegmentReport `json:"segments,omitempty"`
+	// Artifacts maps the names of secondary outputs to their URLs.
+	Artifacts map[string]string `json:"artifacts,omitempty"`
+	// Outputs maps the extra formats requested for the video to their URLs.
+	Outputs map[string]string `json:"outputs,omitempty"`
+
+	// Chapters are the chapter markers of the video, one per slide, and
+	// ChapterDescription lists them as timestamps for a video description.
+	Chapters           []video.Chapter `json:"chapters,omitempty"`
+	ChapterDescription string          `json:"chapter_description,omitempty"`
+}
+
+// SegmentReport records how a single audio segment of a render was produced.
+type SegmentReport struct {
+	Index          int      `json:"index"`
+	Slide          int      `json:"slide"`
+	Speaker        string   `json:"speaker,omitempty"`
+	Silent         bool     `json:"silent,omitempty"`   // Slide without notes, no TTS involved
+	Recorded       bool     `json:"recorded,omitempty"` // Cut from an uploaded recording
+	Cached         bool     `json:"cached,omitempty"`   // Audio reused from the previous render
+	Engine         string   `json:"engine"`
+	Voice          string   `json:"voice"`
+	Fallback       bool     `json:"fallback,omitempty"`
+	FailedAttempts []string `json:"failed_attempts,omitempty"`
+
+	Loudness *audio.NormalizeReport `json:"loudness,omitempty"`
 }
 
 type JobManager struct {
@@ -76,6 +106,58 @@ func (jm *JobManager) UpdateProgress(id string, progress int, message string) {
 	}
 }
 
+func (jm *JobManager) RecordSegment(id string, report SegmentReport) {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+	if job, ok := jm.jobs[id]; ok {
+		job.Segments = append(job.Segments, report)
+	}
+}
+
+// UpdateSegment applies update to the recorded report of segment index.
+func (jm *JobManager) UpdateSegment(id string, index int, update func(*SegmentReport)) {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+	if job, ok := jm.jobs[id]; ok {
+		for i := range job.Segments {
+			if job.Segments[i].Index == index {
+				update(&job.Segments[i])
+			}
+		}
+	}
+}
+
+func (jm *JobManager) AddArtifact(id string, name string, url string) {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+	if job, ok := jm.jobs[id]; ok {
+		if job.Artifacts == nil {
+			job.Artifacts = make(map[string]string)
+		}
+		job.Artifacts[name] = url
+	}
+}
+
+func (jm *JobManager) AddOutput(id string, format string, url string) {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+	if job, ok := jm.jobs[id]; ok {
+		if job.Outputs == nil {
+			job.Outputs = make(map[string]string)
+		}
+		job.Outputs[format] = url
+	}
+}
+
+func (jm *JobManager) SetChapters(id string, chapters []video.Chapter) {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+	if job, ok := jm.jobs[id]; ok {
+		job.Chapters = chapters
+		job.ChapterDescription = video.ChapterDescription(chapters)
+	}
+}
+
 func (jm *JobManager) CompleteJob(id string, downloadURL string) {
 	jm.mu.Lock()
 	defer jm.mu.Unlock()
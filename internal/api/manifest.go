@@ -0,0 +1,181 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/LeonRhapsody/pptTovideo/internal/config"
+	"github.com/LeonRhapsody/pptTovideo/internal/tts"
+	"github.com/LeonRhapsody/pptTovideo/internal/video"
+)
+
+// manifestFile records what the last render of a job produced, so that the
+// next render can reuse it.
+const manifestFile = "manifest.json"
+
+// cacheDir holds synthesized audio kept between renders of a job.
+const cacheDir = "audio_cache"
+
+// renderManifest maps the hash of everything that determines a piece of
+// output to where that output is stored.
+type renderManifest struct {
+	// Segments maps segment keys to their synthesized audio in cacheDir.
+	Segments map[string]cachedSegment `json:"segments"`
+
+	// OutputKey covers every input of the last completed render, whose video
+	// is Output, relative to the work dir.
+	OutputKey string `json:"output_key,omitempty"`
+	Output    string `json:"output,omitempty"`
+
+	// Exports maps formats to the files converted from Output.
+	Exports map[string]string `json:"exports,omitempty"`
+}
+
+// cachedSegment is the result of synthesizing one segment.
+type cachedSegment struct {
+	Audio  string      `json:"audio"` // File name in cacheDir
+	Spoken string      `json:"spoken"`
+	Timing *tts.Result `json:"timing"`
+	Engine string      `json:"engine"`
+	Voice  string      `json:"voice"`
+}
+
+// loadManifest reads the manifest of a job. A missing or unreadable manifest
+// yields an empty one, which just means nothing is reused.
+func loadManifest(workDir string) *renderManifest {
+	m := &renderManifest{}
+	if data, err := os.ReadFile(filepath.Join(workDir, manifestFile)); err == nil {
+		if err := json.Unmarshal(data, m); err != nil {
+			fmt.Printf("Warning: Ignoring unreadable render manifest: %v\n", err)
+			m = &renderManifest{}
+		}
+	}
+	if m.Segments == nil {
+		m.Segments = make(map[string]cachedSegment)
+	}
+	return m
+}
+
+func (m *renderManifest) save(workDir string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(workDir, manifestFile), data, 0644)
+}
+
+// lookup returns the cached synthesis of key if its audio is still on disk.
+// Entries without timings, as older or hand-edited manifests may hold, are
+// synthesized again.
+func (m *renderManifest) lookup(workDir string, key string) (cachedSegment, bool) {
+	seg, ok := m.Segments[key]
+	if !ok || seg.Timing == nil {
+		return cachedSegment{}, false
+	}
+	if _, err := os.Stat(filepath.Join(workDir, cacheDir, seg.Audio)); err != nil {
+		return cachedSegment{}, false
+	}
+	return seg, true
+}
+
+// prune drops the segments whose keys are not in used and deletes their audio.
+func (m *renderManifest) prune(workDir string, used map[string]bool) {
+	for key, seg := range m.Segments {
+		if !used[key] {
+			os.Remove(filepath.Join(workDir, cacheDir, seg.Audio))
+			delete(m.Segments, key)
+		}
+	}
+}
+
+// segmentKey hashes everything that determines the synthesized audio of a
+// segment: its text, voice and prosody, and how pauses are produced.
+func segmentKey(seg renderSegment, pacing Pacing) string {
+	return hashJSON(struct {
+		Text          string
+		Voice         VoiceSettings
+		TrimSilence   bool
+		PauseDuration float64
+	}{seg.Text, seg.Voice, pacing.TrimSilence, pacing.PauseDuration})
+}
+
+// outputKey hashes the request, its encoding profile and subtitle style, the
+// fallback voices and custom engines it narrates with, together with the
+// content of every file it refers to, so that a changed image, upload, preset
+// or engine invalidates the output. The requested formats are left out: they
+// are converted from the output.
+func outputKey(req RenderRequest, profile config.EncodingProfile, style config.SubtitleStyle, fallbacks []config.TTSFallback, engines []config.CustomEngine, segments []renderSegment, extraFiles []string) (string, error) {
+	req.Outputs = nil
+	files := make(map[string]bool)
+	for _, seg := range segments {
+		files[seg.ImagePath] = true
+		if seg.Recording != "" {
+			files[seg.Recording] = true
+		}
+		if seg.Presenter != "" {
+			files[seg.Presenter] = true
+		}
+	}
+	for _, f := range extraFiles {
+		files[f] = true
+	}
+
+	paths := make([]string, 0, len(files))
+	for f := range files {
+		paths = append(paths, f)
+	}
+	sort.Strings(paths)
+
+	h := sha256.New()
+	if err := json.NewEncoder(h).Encode(req); err != nil {
+		return "", err
+	}
+	if err := json.NewEncoder(h).Encode(profile); err != nil {
+		return "", err
+	}
+	if err := json.NewEncoder(h).Encode(style); err != nil {
+		return "", err
+	}
+	if err := json.NewEncoder(h).Encode(fallbacks); err != nil {
+		return "", err
+	}
+	if err := json.NewEncoder(h).Encode(engines); err != nil {
+		return "", err
+	}
+	// Uploads replace fonts in place, so the font file is part of the key.
+	// Its size and modification time stand in for its content, which can be
+	// tens of megabytes.
+	if req.EnableSubtitles {
+		if f, ok := video.SubtitleFont(style.FontFamily); ok {
+			fmt.Fprintf(h, "%s#%d", f.Path, f.Index)
+			if info, err := os.Stat(f.Path); err == nil {
+				fmt.Fprintf(h, ":%d:%d", info.Size(), info.ModTime().UnixNano())
+			}
+		}
+	}
+	for _, p := range paths {
+		f, err := os.Open(p)
+		if err != nil {
+			return "", err
+		}
+		io.WriteString(h, p)
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashJSON(v interface{}) string {
+	data, _ := json.Marshal(v)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,193 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/LeonRhapsody/pptTovideo/internal/audio"
+	"github.com/LeonRhapsody/pptTovideo/internal/tts"
+)
+
+// pauseMarker in notes inserts a pause of Pacing.PauseDuration.
+const pauseMarker = "[停顿]"
+
+// Pacing controls the silence in the narration. Durations are in seconds.
+type Pacing struct {
+	// TrimSilence removes the leading and trailing silence engines leave
+	// around their audio, so that the gaps below are the only pauses.
+	TrimSilence bool `json:"trim_silence"`
+	// SentenceGap follows a segment when the next one is on the same slide.
+	SentenceGap float64 `json:"sentence_gap"`
+	// SlideGap follows the last segment of a slide.
+	SlideGap float64 `json:"slide_gap"`
+	// PauseDuration replaces each pause marker in the notes.
+	PauseDuration float64 `json:"pause_duration"`
+	// EmptySlideDuration is how long a slide without notes is shown.
+	EmptySlideDuration float64 `json:"empty_slide_duration"`
+}
+
+// defaultPacing is used when a render request does not specify pacing.
+var defaultPacing = Pacing{
+	TrimSilence:        true,
+	SentenceGap:        0.3,
+	SlideGap:           0.8,
+	PauseDuration:      0.8,
+	EmptySlideDuration: 3,
+}
+
+// requestPacing returns the pacing of req, defaultPacing if it has none.
+func requestPacing(req RenderRequest) Pacing {
+	if req.Pacing == nil {
+		return defaultPacing
+	}
+	return req.Pacing.withDefaults()
+}
+
+// withDefaults replaces durations that would produce an empty segment.
+func (p Pacing) withDefaults() Pacing {
+	if p.EmptySlideDuration <= 0 {
+		p.EmptySlideDuration = defaultPacing.EmptySlideDuration
+	}
+	if p.PauseDuration < 0 {
+		p.PauseDuration = 0
+	}
+	return p
+}
+
+// synthesizeSegment speaks text with chain and writes the audio to outPath.
+// Pause markers are replaced by real silence and, if enabled, the silence
+// engines leave around each spoken piece is trimmed. It returns the spoken
+// text, with each marker replaced by a space, which the boundaries refer to.
+func synthesizeSegment(chain *tts.FallbackChain, text string, outPath string, opts tts.Options, pacing Pacing) (string, *tts.Synthesis, error) {
+	base := strings.TrimSuffix(outPath, filepath.Ext(outPath))
+	pieces := strings.Split(text, pauseMarker)
+
+	result := &tts.Synthesis{Result: &tts.Result{}}
+	var parts []string
+	offset := 0 // Rune offset of the current piece in the spoken text
+
+	for k, piece := range pieces {
+		if k > 0 {
+			offset++ // The space that replaced the marker
+			if pacing.PauseDuration > 0 {
+				pause := fmt.Sprintf("%s_pause_%d.wav", base, k)
+				if err := audio.Silence(pacing.PauseDuration, pause); err != nil {
+					return "", nil, err
+				}
+				parts = append(parts, pause)
+				result.Result.Duration += pacing.PauseDuration
+			}
+		}
+
+		pieceOffset := offset
+		offset += utf8.RuneCountInString(piece)
+		trimmed := strings.TrimSpace(piece)
+		if trimmed == "" {
+			continue
+		}
+		pieceOffset += utf8.RuneCountInString(piece[:strings.Index(piece, trimmed)])
+
+		raw := fmt.Sprintf("%s_%d.mp3", base, k)
+		synth, err := chain.Synthesize(trimmed, raw, opts)
+		if err != nil {
+			return "", nil, err
+		}
+		result.Voice = synth.Voice
+		result.Failures = append(result.Failures, synth.Failures...)
+		result.Result.Estimated = result.Result.Estimated || synth.Result.Estimated
+
+		part, shift, dur := raw, 0.0, synth.Result.Duration
+		if pacing.TrimSilence {
+			part = fmt.Sprintf("%s_%d_trim.wav", base, k)
+			trim, err := audio.TrimSilence(raw, part)
+			if err != nil {
+				return "", nil, err
+			}
+			shift, dur = trim.Start, trim.Duration
+		}
+
+		for _, b := range synth.Result.Boundaries {
+			b.Offset += pieceOffset
+			b.Start = clamp(b.Start-shift, 0, dur) + result.Result.Duration
+			b.End = clamp(b.End-shift, 0, dur) + result.Result.Duration
+			result.Result.Boundaries = append(result.Result.Boundaries, b)
+		}
+		result.Result.Duration += dur
+		parts = append(parts, part)
+	}
+
+	if len(parts) == 0 {
+		return "", nil, fmt.Errorf("nothing to speak")
+	}
+	if err := audio.Concat(parts, outPath); err != nil {
+		return "", nil, err
+	}
+	return strings.ReplaceAll(text, pauseMarker, " "), result, nil
+}
+
+// normalizeSegments applies loudness normalization to the audio of every
+// segment and records the measured loudness on the job. Silent segments,
+// such as the placeholder for a slide without notes, are left untouched, and
+// recordings have been normalized as a whole before they were cut.
+func normalizeSegments(jobID string, segments []renderSegment, audioDir string, target audio.LoudnessTarget) error {
+	for i, seg := range segments {
+		if seg.Recording != "" {
+			continue
+		}
+		progress := 80 + int(float64(i)/float64(len(segments))*5.0)
+		GlobalJobManager.UpdateProgress(jobID, progress, fmt.Sprintf("Normalizing loudness %d/%d", i+1, len(segments)))
+
+		normPath := filepath.Join(audioDir, fmt.Sprintf("audio_%d_norm.wav", i))
+		report, err := audio.Normalize(seg.AudioPath, normPath, target)
+		if errors.Is(err, audio.ErrSilent) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("segment %d: %w", i+1, err)
+		}
+
+		segments[i].AudioPath = normPath
+		GlobalJobManager.UpdateSegment(jobID, i, func(r *SegmentReport) {
+			r.Loudness = &report
+		})
+	}
+	return nil
+}
+
+// padSegments appends the configured gap after every segment: the sentence
+// gap between segments of the same slide and the slide gap after the last
+// segment of a slide. No gap follows the final segment, and recordings keep
+// the presenter's own pacing within a slide.
+func padSegments(jobID string, segments []renderSegment, audioDir string, pacing Pacing) error {
+	for i := 0; i+1 < len(segments); i++ {
+		gap := pacing.SlideGap
+		if segments[i+1].Slide == segments[i].Slide {
+			gap = pacing.SentenceGap
+			if segments[i].Recording != "" {
+				gap = 0
+			}
+		}
+		if gap <= 0 {
+			continue
+		}
+
+		GlobalJobManager.UpdateProgress(jobID, 85, fmt.Sprintf("Inserting pauses %d/%d", i+1, len(segments)-1))
+
+		padded := filepath.Join(audioDir, fmt.Sprintf("audio_%d_padded.wav", i))
+		if err := audio.PadEnd(segments[i].AudioPath, padded, gap); err != nil {
+			return fmt.Errorf("segment %d: %w", i+1, err)
+		}
+		segments[i].AudioPath = padded
+		if segments[i].Timing != nil {
+			segments[i].Timing.Duration += gap
+		}
+	}
+	return nil
+}
+
+func clamp(v, lo, hi float64) float64 {
+	return max(lo, min(v, hi))
+}
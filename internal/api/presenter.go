@@ -0,0 +1,83 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/LeonRhapsody/pptTovideo/internal/audio"
+	"github.com/LeonRhapsody/pptTovideo/internal/video"
+	"github.com/gin-gonic/gin"
+)
+
+// presenterDir holds the presenter recordings uploaded for a job.
+const presenterDir = "presenter"
+
+// Presenter places a recording of the presenter picture-in-picture. File
+// names a recording of the whole talk uploaded through /api/presenter; slides
+// can show recordings of their own instead. Zero values fall back to the
+// defaults of video.Presenter.
+type Presenter struct {
+	File       string  `json:"file"`
+	Position   string  `json:"position"` // "top_left", ..., "center"; default "bottom_right"
+	Width      float64 `json:"width"`    // Relative to the video width
+	Margin     int     `json:"margin"`   // Pixels
+	Radius     float64 `json:"radius"`   // Corner radius relative to the short side, 0.5 for a circle
+	ChromaKey  string  `json:"chroma_key"`
+	Similarity float64 `json:"similarity"`
+}
+
+// settings returns the presenter settings of p showing the recording at path.
+func (p *Presenter) settings(path string) *video.Presenter {
+	return &video.Presenter{
+		Path:       path,
+		Position:   p.Position,
+		Width:      p.Width,
+		Margin:     p.Margin,
+		Radius:     p.Radius,
+		ChromaKey:  p.ChromaKey,
+		Similarity: p.Similarity,
+	}
+}
+
+// HandleUploadPresenter stores a presenter recording for a job: for a single
+// slide if the form has a "slide" index, else for the whole deck.
+func (h *Handler) HandleUploadPresenter(c *gin.Context) {
+	workDir, ok := jobWorkDir(c.PostForm("job_id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job expired or not found"})
+		return
+	}
+
+	base := "deck"
+	if s := c.PostForm("slide"); s != "" {
+		slide, err := strconv.Atoi(s)
+		if err != nil || slide < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid slide index"})
+			return
+		}
+		base = fmt.Sprintf("slide_%d", slide)
+	}
+
+	name, err := saveJobUploadAs(c, workDir, presenterDir, clipExtensions, base)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	path := filepath.Join(workDir, presenterDir, name)
+	duration, err := audio.Duration(path)
+	if err != nil || duration <= 0 {
+		os.Remove(path)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Uploaded file is not a readable video"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"file":     name,
+		"url":      "/" + filepath.ToSlash(path),
+		"duration": duration,
+	})
+}
@@ -0,0 +1,20 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/LeonRhapsody/pptTovideo/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// HandleListProfiles lists the encoding profiles a render can select with
+// its quality field.
+func (h *Handler) HandleListProfiles(c *gin.Context) {
+	profiles := h.Config.Profiles()
+	for i := range profiles {
+		if profiles[i].Label == "" {
+			profiles[i].Label = profiles[i].Name
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"profiles": profiles, "default": config.DefaultEncodingProfile})
+}
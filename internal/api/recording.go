@@ -0,0 +1,134 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/LeonRhapsody/pptTovideo/internal/audio"
+	"github.com/LeonRhapsody/pptTovideo/internal/tts"
+	"github.com/gin-gonic/gin"
+)
+
+// narrationDir holds the narration recorded by the presenter for a job.
+const narrationDir = "narration"
+
+// HandleUploadNarration stores a recording of the narration of one slide.
+// Any file ffmpeg can decode is accepted; the returned name is passed back as
+// SlideData.Narration to use it in place of TTS.
+func (h *Handler) HandleUploadNarration(c *gin.Context) {
+	workDir, ok := jobWorkDir(c.PostForm("job_id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job expired or not found"})
+		return
+	}
+	slide, err := strconv.Atoi(c.PostForm("slide"))
+	if err != nil || slide < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid slide index"})
+		return
+	}
+
+	name, err := saveJobUploadAs(c, workDir, narrationDir, nil, fmt.Sprintf("slide_%d", slide))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	path := filepath.Join(workDir, narrationDir, name)
+	duration, err := audio.Duration(path)
+	if err != nil || duration <= 0 {
+		os.Remove(path)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Uploaded file contains no readable audio"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"file":     name,
+		"url":      "/" + filepath.ToSlash(path),
+		"duration": duration,
+	})
+}
+
+// minSegmentDuration is the length of a segment that gets no part of a
+// recording, such as one holding only punctuation.
+const minSegmentDuration = 0.02
+
+// splitRecording cuts the recorded narration of a slide into the audio of its
+// segments, which must be consecutive. Without timings from a TTS engine, the
+// timings are estimated from the text, giving each segment a share of the
+// recording proportional to how long its text takes to say.
+func splitRecording(recording string, segments []renderSegment, audioDir string, first int) error {
+	total, err := audio.Duration(recording)
+	if err != nil {
+		return err
+	}
+
+	// Estimate over the text of the whole slide, so that each cut falls where
+	// its sentence would end in the recording.
+	texts := make([]string, len(segments))
+	for i, seg := range segments {
+		texts[i] = strings.ReplaceAll(seg.Text, pauseMarker, " ")
+	}
+	boundaries := tts.EstimateBoundaries(strings.Join(texts, " "), total)
+
+	starts := make([]float64, len(segments)+1)
+	starts[len(segments)] = total
+	lo := 0
+	for i, text := range texts {
+		hi := lo + utf8.RuneCountInString(text)
+		starts[i] = total
+		for _, b := range boundaries {
+			if b.Offset >= lo && b.Offset < hi {
+				starts[i] = b.Start
+				break
+			}
+		}
+		lo = hi + 1 // The joining space
+	}
+	starts[0] = 0
+	// Segments without spoken words get no audio of their own.
+	for i := len(segments) - 1; i > 0; i-- {
+		starts[i] = min(starts[i], starts[i+1])
+	}
+
+	lo = 0
+	for i := range segments {
+		start, end := starts[i], starts[i+1]
+		out := filepath.Join(audioDir, fmt.Sprintf("audio_%d.wav", first+i))
+		switch {
+		case i == len(segments)-1:
+			// Keep everything up to the end of the recording.
+			err = audio.Cut(recording, out, start, 0)
+		case end > start:
+			err = audio.Cut(recording, out, start, end)
+		default:
+			// Nothing left to say; ffmpeg cannot write an empty cut.
+			end = start + minSegmentDuration
+			err = audio.Silence(minSegmentDuration, out)
+		}
+		if err != nil {
+			return err
+		}
+
+		hi := lo + utf8.RuneCountInString(texts[i])
+		result := &tts.Result{Duration: end - start, Estimated: true}
+		for _, b := range boundaries {
+			if b.Offset >= lo && b.Offset < hi {
+				b.Offset -= lo
+				b.Start = clamp(b.Start-start, 0, end-start)
+				b.End = clamp(b.End-start, 0, end-start)
+				result.Boundaries = append(result.Boundaries, b)
+			}
+		}
+		lo = hi + 1
+
+		segments[i].AudioPath = out
+		segments[i].Spoken = texts[i]
+		segments[i].Timing = result
+	}
+	return nil
+}
@@ -0,0 +1,708 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/LeonRhapsody/pptTovideo/internal/audio"
+	"github.com/LeonRhapsody/pptTovideo/internal/ppt"
+	"github.com/LeonRhapsody/pptTovideo/internal/tts"
+	"github.com/LeonRhapsody/pptTovideo/internal/video"
+)
+
+// VoiceSettings selects an engine, voice and prosody. Empty fields inherit
+// from the enclosing scope: render request, then slide, then speaker.
+type VoiceSettings struct {
+	EngineType string `json:"engine_type,omitempty"`
+	VoiceName  string `json:"voice_name,omitempty"`
+	Rate       string `json:"rate,omitempty"`
+	Volume     string `json:"volume,omitempty"`
+	Pitch      string `json:"pitch,omitempty"`
+}
+
+// merge returns s overridden by the non-empty fields of o.
+// Switching engine without naming a voice selects the new engine's default
+// voice, since voice names are not portable between engines.
+func (s VoiceSettings) merge(o *VoiceSettings) VoiceSettings {
+	if o == nil {
+		return s
+	}
+	if o.EngineType != "" && o.EngineType != s.EngineType {
+		s.EngineType = o.EngineType
+		s.VoiceName = ""
+	}
+	if o.VoiceName != "" {
+		s.VoiceName = o.VoiceName
+	}
+	if o.Rate != "" {
+		s.Rate = o.Rate
+	}
+	if o.Volume != "" {
+		s.Volume = o.Volume
+	}
+	if o.Pitch != "" {
+		s.Pitch = o.Pitch
+	}
+	return s
+}
+
+func (s VoiceSettings) voice() tts.Voice {
+	return tts.Voice{Engine: tts.EngineType(s.EngineType), Name: s.VoiceName}
+}
+
+func (s VoiceSettings) options() tts.Options {
+	return tts.Options{Rate: s.Rate, Volume: s.Volume, Pitch: s.Pitch}
+}
+
+// renderSegment is one unit of narration: a piece of text spoken by a single
+// voice over a single slide image.
+type renderSegment struct {
+	Slide     int
+	Speaker   string
+	Text      string
+	Voice     VoiceSettings
+	AudioPath string
+	ImagePath string
+
+	// Spoken is the text sent to TTS and Timing the word or character
+	// boundaries of the audio; boundary offsets refer to Spoken.
+	Spoken string
+	Timing *tts.Result
+
+	// Recording is the uploaded narration of the slide this segment is cut
+	// from, if the slide is not narrated by TTS.
+	Recording string
+
+	// Motion is the camera motion of the slide, nil for the render default.
+	Motion *video.Motion
+
+	// Presenter is the presenter recording of the slide, if it has its own.
+	Presenter string
+
+	// Callout is the region pointed out while the segment plays, if any.
+	Callout *video.Callout
+}
+
+// subtitle returns the text shown on screen while the segment plays.
+func (s renderSegment) subtitle() string {
+	return strings.TrimSpace(strings.ReplaceAll(s.Text, pauseMarker, " "))
+}
+
+// speakerTurn is a run of notes text attributed to one speaker.
+type speakerTurn struct {
+	Speaker string
+	Text    string
+}
+
+// speakerTagPattern matches a speaker tag such as "A:" or "主持人：" at the
+// start of a line.
+var speakerTagPattern = regexp.MustCompile(`^\s*([^\s:：]{1,16})\s*[:：]\s*`)
+
+// splitSpeakerTurns splits notes into turns at lines starting with a tag that
+// is present in speakers. Text before the first tag, and all text when no
+// speakers are configured, belongs to the default speaker "".
+func splitSpeakerTurns(text string, speakers map[string]VoiceSettings) []speakerTurn {
+	if len(speakers) == 0 {
+		return []speakerTurn{{Text: text}}
+	}
+
+	var turns []speakerTurn
+	current := speakerTurn{}
+	var lines []string
+
+	flush := func() {
+		current.Text = strings.TrimSpace(strings.Join(lines, "\n"))
+		if current.Text != "" {
+			turns = append(turns, current)
+		}
+		lines = nil
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		if m := speakerTagPattern.FindStringSubmatchIndex(line); m != nil {
+			tag := line[m[2]:m[3]]
+			if _, ok := speakers[tag]; ok {
+				flush()
+				current = speakerTurn{Speaker: tag}
+				line = line[m[1]:]
+			}
+		}
+		lines = append(lines, line)
+	}
+	flush()
+
+	return turns
+}
+
+// buildSegments turns the slides of a render request into narration segments,
+// resolving the voice of each one. Recordings are looked up in workDir.
+func buildSegments(req RenderRequest, imageDir string, workDir string) []renderSegment {
+	base := VoiceSettings{
+		EngineType: req.EngineType,
+		VoiceName:  req.VoiceName,
+		Rate:       req.Rate,
+		Volume:     req.Volume,
+		Pitch:      req.Pitch,
+	}
+
+	var segments []renderSegment
+	for _, slide := range req.Slides {
+		urlParts := strings.Split(slide.ImageURL, "/")
+		filename := urlParts[len(urlParts)-1]
+		// Use the potentially re-generated image directory
+		imgPath := filepath.Join(imageDir, filename)
+
+		slideVoice := base.merge(slide.Voice)
+
+		notes, motion := extractFocus(slide.Text)
+		if motion == nil {
+			motion = slide.Motion
+		}
+		notes, callouts := markCallouts(notes)
+
+		recording := ""
+		if slide.Narration != "" {
+			recording = filepath.Join(workDir, narrationDir, filepath.Base(slide.Narration))
+		}
+		presenter := ""
+		if slide.Presenter != "" {
+			presenter = filepath.Join(workDir, presenterDir, filepath.Base(slide.Presenter))
+		}
+
+		if text, callout := takeCallout(notes, callouts); text == "" {
+			segments = append(segments, renderSegment{
+				Slide:     slide.Index,
+				Voice:     slideVoice,
+				ImagePath: imgPath,
+				Recording: recording,
+				Motion:    motion,
+				Presenter: presenter,
+				Callout:   callout,
+			})
+			continue
+		}
+
+		first := len(segments)
+
+		for _, turn := range splitSpeakerTurns(notes, req.Speakers) {
+			voice := slideVoice
+			if turn.Speaker != "" {
+				speaker := req.Speakers[turn.Speaker]
+				voice = slideVoice.merge(&speaker)
+			}
+
+			// One segment per turn, unless something below is timed by
+			// sentence.
+			texts := []string{turn.Text}
+			// Callouts last for the sentence they are in, and translated
+			// tracks are timed by sentence, so they need sentence segments
+			// even without subtitles.
+			if req.EnableSubtitles || len(callouts) > 0 || req.Translation != nil {
+				if sentences := splitTextIntoSentences(turn.Text); len(sentences) > 0 {
+					texts = sentences
+				}
+			}
+			for _, text := range texts {
+				text, callout := takeCallout(text, callouts)
+				if text == "" {
+					// A directive after the end of a sentence belongs to it.
+					if callout != nil && len(segments) > first {
+						segments[len(segments)-1].Callout = callout
+					}
+					continue
+				}
+				segments = append(segments, renderSegment{
+					Slide:     slide.Index,
+					Speaker:   turn.Speaker,
+					Text:      text,
+					Voice:     voice,
+					ImagePath: imgPath,
+					Recording: recording,
+					Motion:    motion,
+					Presenter: presenter,
+					Callout:   callout,
+				})
+			}
+		}
+	}
+	return segments
+}
+
+// render runs the TTS and video pipeline of a render job in the background.
+func (h *Handler) render(jobID, workDir string, req RenderRequest) {
+	// Quotas of the next render are checked against what this one used.
+	defer h.flushUsage()
+
+	GlobalJobManager.UpdateProgress(jobID, 10, "Initializing...")
+
+	// Slides are rasterized at 150 DPI, about 1125 pixels high for 16:9,
+	// unless the profile needs more.
+	profile, _ := h.Config.EncodingProfile(req.Quality) // Validated by HandleRender
+	if req.TargetSizeMB > 0 {
+		profile.TargetSizeMB = req.TargetSizeMB
+	}
+	// Presets may change between renders, so the style is resolved once and
+	// used for both the output key and the video.
+	subtitleStyle, _ := h.subtitleStyle(req) // Validated by HandleRender
+	dpi := 150
+	if profile.Resolution > 1080 {
+		dpi = (150*profile.Resolution + 1079) / 1080
+	}
+
+	// Re-generate images if DPI is different from default (150)
+	imageDir := filepath.Join(workDir, "images")
+	if dpi != 150 {
+		imageDir = filepath.Join(workDir, fmt.Sprintf("images_%d", dpi))
+		if _, err := os.Stat(imageDir); os.IsNotExist(err) {
+			GlobalJobManager.UpdateProgress(jobID, 12, fmt.Sprintf("Re-generating images for %s...", profile.Name))
+			pptxPath := ""
+			// Find pptx file in workDir
+			files, _ := ioutil.ReadDir(workDir)
+			for _, f := range files {
+				if strings.HasSuffix(strings.ToLower(f.Name()), ".pptx") {
+					pptxPath = filepath.Join(workDir, f.Name())
+					break
+				}
+			}
+			if pptxPath != "" {
+				_, err := ppt.ConvertSlidesToImages(pptxPath, imageDir, dpi)
+				if err != nil {
+					GlobalJobManager.FailJob(jobID, "Failed to re-generate high-quality images: "+err.Error())
+					return
+				}
+			}
+		}
+	}
+
+	audioDir := filepath.Join(workDir, "audio_render")
+	os.MkdirAll(audioDir, 0755)
+
+	segments := buildSegments(req, imageDir, workDir)
+
+	// Build one fallback chain per distinct voice up front, so an invalid
+	// engine anywhere in the deck fails the job before any synthesis.
+	fallbacks := req.Fallbacks
+	if fallbacks == nil {
+		fallbacks = h.Config.Fallbacks()
+	}
+	meter := func(engine tts.EngineType, text string, failed bool) {
+		h.recordUsage(jobID, engine, text, failed)
+	}
+	chains := make(map[tts.Voice]*tts.FallbackChain)
+	for _, seg := range segments {
+		v := seg.Voice.voice()
+		if _, ok := chains[v]; ok || seg.Text == "" || seg.Recording != "" {
+			continue
+		}
+		chain, err := tts.NewFallbackChain(append([]tts.Voice{v}, tts.FallbackVoices(fallbacks)...), h.Config, h.Voices, meter)
+		if err != nil {
+			GlobalJobManager.FailJob(jobID, "Invalid TTS engine: "+err.Error())
+			return
+		}
+		chains[v] = chain
+	}
+
+	pacing := requestPacing(req)
+
+	var musicPath string
+	var extraFiles []string
+	if m := req.BackgroundMusic; m != nil {
+		musicPath, _ = jobUploadPath(workDir, musicDir, m.File) // Validated by HandleRender
+		extraFiles = append(extraFiles, musicPath)
+	}
+
+	var branding *video.Branding
+	if req.Branding != nil {
+		branding, _ = req.Branding.resolve(workDir) // Validated by HandleRender
+		for _, f := range []string{branding.Intro, branding.Outro} {
+			if f != "" {
+				extraFiles = append(extraFiles, f)
+			}
+		}
+		if branding.Watermark != nil {
+			extraFiles = append(extraFiles, branding.Watermark.Path)
+		}
+	}
+
+	placement := req.Presenter
+	if placement == nil {
+		placement = &Presenter{}
+	}
+	var presenter *video.Presenter
+	if placement.File != "" {
+		presenter = placement.settings(filepath.Join(workDir, presenterDir, filepath.Base(placement.File)))
+		extraFiles = append(extraFiles, presenter.Path)
+	}
+
+	// Skip everything if nothing changed since the last render.
+	manifest := loadManifest(workDir)
+	key, err := outputKey(req, profile, subtitleStyle, fallbacks, h.Config.Engines(), segments, extraFiles)
+	if err != nil {
+		fmt.Printf("Warning: Failed to hash render inputs: %v\n", err)
+	} else if manifest.OutputKey == key && manifest.Output != "" {
+		if _, err := os.Stat(filepath.Join(workDir, manifest.Output)); err == nil {
+			GlobalJobManager.UpdateProgress(jobID, 95, "No changes since the last render")
+			if _, err := os.Stat(filepath.Join(workDir, timingsFile)); err == nil {
+				GlobalJobManager.AddArtifact(jobID, "timings", fmt.Sprintf("/uploads/%s/%s", req.JobID, timingsFile))
+			}
+			if chapters, err := readChapters(filepath.Join(workDir, chaptersFile)); err == nil {
+				GlobalJobManager.SetChapters(jobID, chapters)
+				GlobalJobManager.AddArtifact(jobID, "chapters", fmt.Sprintf("/uploads/%s/%s", req.JobID, chaptersFile))
+			}
+			if t := req.Translation; t != nil && !t.bilingual() {
+				if _, err := os.Stat(filepath.Join(workDir, t.trackFile())); err == nil {
+					GlobalJobManager.AddArtifact(jobID, "subtitles", fmt.Sprintf("/uploads/%s/%s", req.JobID, t.trackFile()))
+				}
+			}
+			if err := exportOutputs(jobID, workDir, req, manifest); err != nil {
+				GlobalJobManager.FailJob(jobID, err.Error())
+				return
+			}
+			if err := manifest.save(workDir); err != nil {
+				fmt.Printf("Warning: Failed to save render manifest: %v\n", err)
+			}
+			GlobalJobManager.CompleteJob(jobID, fmt.Sprintf("/uploads/%s/%s", req.JobID, manifest.Output))
+			return
+		}
+	}
+
+	os.MkdirAll(filepath.Join(workDir, cacheDir), 0755)
+	usedKeys := make(map[string]bool)
+	reused := 0
+	fellBack := false
+
+	totalSegments := len(segments)
+
+	for i, seg := range segments {
+		progress := 10 + int(float64(i)/float64(totalSegments)*70.0)
+		GlobalJobManager.UpdateProgress(jobID, progress, fmt.Sprintf("Synthesizing audio %d/%d", i+1, totalSegments))
+
+		outPath := filepath.Join(audioDir, fmt.Sprintf("audio_%d.wav", i))
+
+		if seg.Recording != "" {
+			// The first segment of a recorded slide splits the recording
+			// among all of the slide's segments.
+			if segments[i].AudioPath == "" {
+				end := i + 1
+				for end < len(segments) && segments[end].Slide == seg.Slide {
+					end++
+				}
+				recording := seg.Recording
+				if req.Loudness != nil {
+					// Normalize the recording as a whole, so that its level
+					// does not jump at the cuts.
+					normPath := filepath.Join(audioDir, fmt.Sprintf("recording_%d_norm.wav", seg.Slide))
+					if _, err := audio.Normalize(recording, normPath, req.Loudness.WithDefaults()); err == nil {
+						recording = normPath
+					} else if !errors.Is(err, audio.ErrSilent) {
+						GlobalJobManager.FailJob(jobID, fmt.Sprintf("Normalizing narration of slide %d failed: %v", seg.Slide+1, err))
+						return
+					}
+				}
+				if err := splitRecording(recording, segments[i:end], audioDir, i); err != nil {
+					GlobalJobManager.FailJob(jobID, fmt.Sprintf("Using narration of slide %d failed: %v", seg.Slide+1, err))
+					return
+				}
+			}
+			GlobalJobManager.RecordSegment(jobID, SegmentReport{Index: i, Slide: seg.Slide, Speaker: seg.Speaker, Recorded: true})
+			continue
+		}
+
+		if strings.TrimSpace(seg.Text) == "" {
+			// Slide without notes: hold it for a while in silence.
+			if err := audio.Silence(pacing.EmptySlideDuration, outPath); err != nil {
+				GlobalJobManager.FailJob(jobID, fmt.Sprintf("Generating silence for segment %d failed: %v", i+1, err))
+				return
+			}
+			GlobalJobManager.RecordSegment(jobID, SegmentReport{Index: i, Slide: seg.Slide, Silent: true})
+			segments[i].AudioPath = outPath
+			segments[i].Timing = &tts.Result{Duration: pacing.EmptySlideDuration}
+			continue
+		}
+
+		segKey := segmentKey(seg, pacing)
+		usedKeys[segKey] = true
+		if cached, ok := manifest.lookup(workDir, segKey); ok {
+			reused++
+			GlobalJobManager.UpdateProgress(jobID, progress, fmt.Sprintf("Reusing unchanged audio %d/%d", i+1, totalSegments))
+			GlobalJobManager.RecordSegment(jobID, SegmentReport{
+				Index:   i,
+				Slide:   seg.Slide,
+				Speaker: seg.Speaker,
+				Engine:  cached.Engine,
+				Voice:   cached.Voice,
+				Cached:  true,
+			})
+			timing := *cached.Timing
+			segments[i].AudioPath = filepath.Join(workDir, cacheDir, cached.Audio)
+			segments[i].Spoken = cached.Spoken
+			segments[i].Timing = &timing
+			continue
+		}
+
+		spoken, synth, err := synthesizeSegment(chains[seg.Voice.voice()], seg.Text, outPath, seg.Voice.options(), pacing)
+		if err != nil {
+			errMsg := fmt.Sprintf("TTS failed for segment %d: %v", i+1, err)
+			GlobalJobManager.FailJob(jobID, errMsg)
+			return
+		}
+
+		// Keep the audio for the next render, unless a fallback voice was
+		// used: the preferred voice gets another chance then.
+		fellBack = fellBack || len(synth.Failures) > 0
+		if len(synth.Failures) == 0 {
+			cachedName := segKey + ".wav"
+			if err := os.Rename(outPath, filepath.Join(workDir, cacheDir, cachedName)); err == nil {
+				outPath = filepath.Join(workDir, cacheDir, cachedName)
+				timing := *synth.Result
+				manifest.Segments[segKey] = cachedSegment{
+					Audio:  cachedName,
+					Spoken: spoken,
+					Timing: &timing,
+					Engine: string(synth.Voice.Engine),
+					Voice:  synth.Voice.Name,
+				}
+			}
+		}
+
+		report := SegmentReport{
+			Index:    i,
+			Slide:    seg.Slide,
+			Speaker:  seg.Speaker,
+			Engine:   string(synth.Voice.Engine),
+			Voice:    synth.Voice.Name,
+			Fallback: len(synth.Failures) > 0,
+		}
+		for _, f := range synth.Failures {
+			report.FailedAttempts = append(report.FailedAttempts, f.Error())
+		}
+		GlobalJobManager.RecordSegment(jobID, report)
+
+		segments[i].AudioPath = outPath
+		segments[i].Spoken = spoken
+		segments[i].Timing = synth.Result
+	}
+
+	manifest.prune(workDir, usedKeys)
+	manifest.OutputKey = ""
+	if err := manifest.save(workDir); err != nil {
+		fmt.Printf("Warning: Failed to save render manifest: %v\n", err)
+	}
+	if reused > 0 {
+		GlobalJobManager.UpdateProgress(jobID, 80, fmt.Sprintf("Reused %d of %d segments", reused, totalSegments))
+	}
+
+	if req.Loudness != nil {
+		if err := normalizeSegments(jobID, segments, audioDir, req.Loudness.WithDefaults()); err != nil {
+			GlobalJobManager.FailJob(jobID, "Loudness normalization failed: "+err.Error())
+			return
+		}
+	}
+
+	if err := padSegments(jobID, segments, audioDir, pacing); err != nil {
+		GlobalJobManager.FailJob(jobID, "Inserting pauses failed: "+err.Error())
+		return
+	}
+
+	if err := writeTimings(filepath.Join(workDir, timingsFile), segments); err != nil {
+		fmt.Printf("Warning: Failed to write timings: %v\n", err)
+	} else {
+		GlobalJobManager.AddArtifact(jobID, "timings", fmt.Sprintf("/uploads/%s/%s", req.JobID, timingsFile))
+	}
+
+	var imagePaths []string
+	var audioPaths []string
+	var texts []string
+	var motions []*video.Motion
+	var presenters []*video.Presenter
+	var callouts []*video.Callout
+	for _, seg := range segments {
+		imagePaths = append(imagePaths, seg.ImagePath)
+		audioPaths = append(audioPaths, seg.AudioPath)
+		texts = append(texts, seg.subtitle())
+		motions = append(motions, seg.Motion)
+		callouts = append(callouts, seg.Callout)
+
+		var p *video.Presenter
+		if seg.Presenter != "" {
+			p = placement.settings(seg.Presenter)
+		}
+		presenters = append(presenters, p)
+	}
+
+	var tracks []video.SubtitleTrack
+	if t := req.Translation; t != nil {
+		GlobalJobManager.UpdateProgress(jobID, 82, "Translating subtitles...")
+		var track *video.SubtitleTrack
+		texts, track, err = h.applyTranslation(t, texts, segments, branding, workDir)
+		if err != nil {
+			GlobalJobManager.FailJob(jobID, fmt.Sprintf("Translation failed: %v", err))
+			return
+		}
+		if track != nil {
+			tracks = append(tracks, *track)
+			GlobalJobManager.AddArtifact(jobID, "subtitles", fmt.Sprintf("/uploads/%s/%s", req.JobID, t.trackFile()))
+		}
+	}
+
+	chapters, err := buildChapters(req, segments, branding)
+	if err != nil {
+		fmt.Printf("Warning: Failed to build chapters: %v\n", err)
+		chapters = nil
+	}
+
+	GlobalJobManager.UpdateProgress(jobID, 85, "Rendering Video...")
+	outputVideoPath := filepath.Join(workDir, fmt.Sprintf("output_%d.mp4", time.Now().Unix()))
+
+	opts := video.RenderOptions{
+		EnableSubtitles: req.EnableSubtitles,
+		SubtitleStyle:   subtitleStyle,
+		Encoding:        profile,
+		Motion:          req.Motion,
+		Motions:         motions,
+		Canvas:          req.Canvas,
+		Branding:        branding,
+		Presenter:       presenter,
+		Presenters:      presenters,
+		Callouts:        callouts,
+		SubtitleTracks:  tracks,
+		Chapters:        chapters,
+	}
+	if m := req.BackgroundMusic; m != nil {
+		opts.Music = &video.MusicOptions{
+			Path:      musicPath,
+			Volume:    defaultFloat(m.Volume, 0.3),
+			FadeIn:    defaultFloat(m.FadeIn, 2),
+			FadeOut:   defaultFloat(m.FadeOut, 3),
+			NoDucking: m.DisableDucking,
+		}
+	}
+
+	if err := video.ComposeVideo(imagePaths, audioPaths, texts, outputVideoPath, opts); err != nil {
+		errMsg := fmt.Sprintf("Video composition failed: %v", err)
+		GlobalJobManager.FailJob(jobID, errMsg)
+		return
+	}
+
+	if len(chapters) > 0 {
+		GlobalJobManager.SetChapters(jobID, chapters)
+		if err := writeChapters(filepath.Join(workDir, chaptersFile), chapters); err != nil {
+			fmt.Printf("Warning: Failed to write chapters: %v\n", err)
+		} else {
+			GlobalJobManager.AddArtifact(jobID, "chapters", fmt.Sprintf("/uploads/%s/%s", req.JobID, chaptersFile))
+		}
+	} else {
+		os.Remove(filepath.Join(workDir, chaptersFile))
+	}
+
+	manifest.Output = filepath.Base(outputVideoPath)
+	manifest.Exports = nil
+	if err := exportOutputs(jobID, workDir, req, manifest); err != nil {
+		GlobalJobManager.FailJob(jobID, err.Error())
+		return
+	}
+
+	// Neither is the video reused if a fallback voice made part of it.
+	if key != "" && !fellBack {
+		manifest.OutputKey = key
+		if err := manifest.save(workDir); err != nil {
+			fmt.Printf("Warning: Failed to save render manifest: %v\n", err)
+		}
+	}
+
+	downloadURL := fmt.Sprintf("/uploads/%s/%s", req.JobID, filepath.Base(outputVideoPath))
+	GlobalJobManager.CompleteJob(jobID, downloadURL)
+}
+
+// exportOutputs converts the video of the manifest into the formats the
+// request asks for, reusing conversions of the same video, and adds them to
+// the job. Converted files are recorded in the manifest.
+func exportOutputs(jobID, workDir string, req RenderRequest, manifest *renderManifest) error {
+	master := filepath.Join(workDir, manifest.Output)
+	opts := video.ExportOptions{TeaserDuration: req.TeaserDuration}
+
+	for i, format := range req.Outputs {
+		name, ok := manifest.Exports[format]
+		if ok {
+			if _, err := os.Stat(filepath.Join(workDir, name)); err != nil {
+				ok = false
+			}
+		}
+		if !ok {
+			GlobalJobManager.UpdateProgress(jobID, 90+i*9/len(req.Outputs), fmt.Sprintf("Exporting %s...", format))
+			out, err := video.Export(master, format, opts)
+			if err != nil {
+				return fmt.Errorf("Exporting %s failed: %v", format, err)
+			}
+			if name, err = filepath.Rel(workDir, out); err != nil {
+				return err
+			}
+			if manifest.Exports == nil {
+				manifest.Exports = make(map[string]string)
+			}
+			manifest.Exports[format] = name
+		}
+		GlobalJobManager.AddOutput(jobID, format, fmt.Sprintf("/uploads/%s/%s", req.JobID, filepath.ToSlash(name)))
+	}
+	return nil
+}
+
+// musicDir holds the background tracks uploaded for a job.
+const musicDir = "music"
+
+func defaultFloat(v, fallback float64) float64 {
+	if v <= 0 {
+		return fallback
+	}
+	return v
+}
+
+// timingsFile is written to the work dir of each render for downstream
+// subtitle and highlighting tools.
+const timingsFile = "timings.json"
+
+// segmentTiming is the on-disk form of the timings of one segment.
+type segmentTiming struct {
+	Index      int            `json:"index"`
+	Slide      int            `json:"slide"`
+	Text       string         `json:"text"`
+	Start      float64        `json:"start"` // Seconds from the start of the narration
+	Duration   float64        `json:"duration"`
+	Estimated  bool           `json:"estimated"`
+	Boundaries []tts.Boundary `json:"boundaries"`
+}
+
+// writeTimings saves the timings of all segments, with segment start times
+// accumulated over the whole narration.
+func writeTimings(path string, segments []renderSegment) error {
+	timings := make([]segmentTiming, 0, len(segments))
+	start := 0.0
+	for i, seg := range segments {
+		if seg.Timing == nil {
+			continue
+		}
+		timings = append(timings, segmentTiming{
+			Index:      i,
+			Slide:      seg.Slide,
+			Text:       seg.Spoken,
+			Start:      start,
+			Duration:   seg.Timing.Duration,
+			Estimated:  seg.Timing.Estimated,
+			Boundaries: seg.Timing.Boundaries,
+		})
+		start += seg.Timing.Duration
+	}
+
+	data, err := json.MarshalIndent(timings, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
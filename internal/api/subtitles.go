@@ -0,0 +1,84 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/LeonRhapsody/pptTovideo/internal/config"
+	"github.com/LeonRhapsody/pptTovideo/internal/video"
+	"github.com/gin-gonic/gin"
+)
+
+// subtitleStyle returns the subtitle style of a render request: its own
+// style, or else that of its preset.
+func (h *Handler) subtitleStyle(req RenderRequest) (config.SubtitleStyle, error) {
+	var style config.SubtitleStyle
+	if req.SubtitleStyle != nil {
+		style = *req.SubtitleStyle
+	} else {
+		name := req.SubtitlePreset
+		if name == "" {
+			name = config.DefaultSubtitlePreset
+		}
+		preset, ok := h.Config.SubtitlePreset(name)
+		if !ok {
+			return style, fmt.Errorf("unknown subtitle preset %q", name)
+		}
+		style = preset.Style
+	}
+	if style.FontSize <= 0 {
+		style.FontSize = req.SubtitleFontSize
+	}
+	return style, video.ValidateSubtitleStyle(style)
+}
+
+// HandleListSubtitlePresets lists the subtitle presets a render can select.
+func (h *Handler) HandleListSubtitlePresets(c *gin.Context) {
+	presets := h.Config.Presets()
+	for i := range presets {
+		if presets[i].Label == "" {
+			presets[i].Label = presets[i].Name
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"presets": presets, "default": config.DefaultSubtitlePreset})
+}
+
+// HandleSaveSubtitlePreset saves a subtitle style under a name, replacing a
+// preset of the same name.
+func (h *Handler) HandleSaveSubtitlePreset(c *gin.Context) {
+	var preset config.SubtitlePreset
+	if err := c.ShouldBindJSON(&preset); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	preset.Name = strings.TrimSpace(preset.Name)
+	if preset.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required"})
+		return
+	}
+	if err := video.ValidateSubtitleStyle(preset.Style); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.Config.SetSubtitlePreset(preset)
+	if err := h.Config.Save(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save config: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, preset)
+}
+
+// HandleDeleteSubtitlePreset removes a saved subtitle preset.
+func (h *Handler) HandleDeleteSubtitlePreset(c *gin.Context) {
+	if !h.Config.DeleteSubtitlePreset(c.Param("name")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Preset not found"})
+		return
+	}
+	if err := h.Config.Save(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save config: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Preset deleted"})
+}
@@ -0,0 +1,192 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/LeonRhapsody/pptTovideo/internal/audio"
+	"github.com/LeonRhapsody/pptTovideo/internal/translate"
+	"github.com/LeonRhapsody/pptTovideo/internal/video"
+)
+
+// Translation modes.
+const (
+	TranslationBilingual = "bilingual" // Both languages drawn onto the video, one under the other
+	TranslationTrack     = "track"     // A soft subtitle track and SRT file in the target language
+)
+
+// translationsFile caches the translations of a job in its work dir, so that
+// re-renders do not pay for them again.
+const translationsFile = "translations.json"
+
+// Translation adds subtitles in a second language.
+type Translation struct {
+	Backend string `json:"backend"` // See translate.Backends, default "openai"
+	Source  string `json:"source"`  // Language of the notes, e.g. "zh"; detected if empty
+	Target  string `json:"target"`  // e.g. "en"
+	Mode    string `json:"mode"`    // TranslationBilingual (default) or TranslationTrack
+}
+
+// Validate reports whether the settings of t are usable.
+func (t *Translation) Validate() error {
+	if t == nil {
+		return nil
+	}
+	if t.Target == "" {
+		return fmt.Errorf("translation needs a target language")
+	}
+	if t.Backend != "" && !slices.Contains(translate.Backends, translate.Backend(t.Backend)) {
+		return fmt.Errorf("unknown translation backend %q", t.Backend)
+	}
+	if t.Mode != "" && t.Mode != TranslationBilingual && t.Mode != TranslationTrack {
+		return fmt.Errorf("unknown translation mode %q", t.Mode)
+	}
+	return nil
+}
+
+func (t *Translation) backend() translate.Backend {
+	if t.Backend == "" {
+		return translate.BackendOpenAI
+	}
+	return translate.Backend(t.Backend)
+}
+
+// bilingual reports whether translations are drawn under the subtitles.
+func (t *Translation) bilingual() bool {
+	return t.Mode == "" || t.Mode == TranslationBilingual
+}
+
+// trackFile is the name of the SRT file of the translated subtitles.
+func (t *Translation) trackFile() string {
+	return fmt.Sprintf("subtitles_%s.srt", filepath.Base(t.Target))
+}
+
+// translateSubtitles returns the translations of texts, reusing those cached
+// in workDir. Empty texts stay empty.
+func (h *Handler) translateSubtitles(t *Translation, texts []string, workDir string) ([]string, error) {
+	cachePath := filepath.Join(workDir, translationsFile)
+	cache := make(map[string]map[string]string)
+	if data, err := os.ReadFile(cachePath); err == nil {
+		if err := json.Unmarshal(data, &cache); err != nil {
+			fmt.Printf("Warning: Ignoring unreadable translation cache: %v\n", err)
+			cache = make(map[string]map[string]string)
+		}
+	}
+	key := h.translationCacheKey(t)
+	known := cache[key]
+	if known == nil {
+		known = make(map[string]string)
+		cache[key] = known
+	}
+
+	var missing []string
+	for _, text := range texts {
+		if _, ok := known[text]; !ok && text != "" && !slices.Contains(missing, text) {
+			missing = append(missing, text)
+		}
+	}
+	if len(missing) > 0 {
+		translator, err := translate.NewTranslator(t.backend(), h.Config)
+		if err != nil {
+			return nil, err
+		}
+		translated, err := translator.Translate(missing, t.Source, t.Target)
+		if err != nil {
+			return nil, err
+		}
+		for i, text := range missing {
+			known[text] = translated[i]
+		}
+		if data, err := json.MarshalIndent(cache, "", "  "); err == nil {
+			if err := os.WriteFile(cachePath, data, 0644); err != nil {
+				fmt.Printf("Warning: Failed to cache translations: %v\n", err)
+			}
+		}
+	}
+
+	out := make([]string, len(texts))
+	for i, text := range texts {
+		out[i] = known[text]
+	}
+	return out, nil
+}
+
+// applyTranslation translates texts, parallel to segments, as t asks. In
+// bilingual mode it returns texts with their translations; in track mode it
+// returns texts unchanged and the SRT track it wrote to workDir, timed by the
+// audio of segments.
+func (h *Handler) applyTranslation(t *Translation, texts []string, segments []renderSegment, branding *video.Branding, workDir string) ([]string, *video.SubtitleTrack, error) {
+	translated, err := h.translateSubtitles(t, texts, workDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	if t.bilingual() {
+		return joinTranslations(texts, translated), nil, nil
+	}
+
+	cues, err := subtitleCues(segments, translated, branding)
+	if err != nil {
+		return nil, nil, fmt.Errorf("timing translated subtitles: %w", err)
+	}
+	track := &video.SubtitleTrack{Path: filepath.Join(workDir, t.trackFile()), Language: t.Target}
+	if err := video.WriteSRT(track.Path, cues); err != nil {
+		return nil, nil, fmt.Errorf("writing translated subtitles: %w", err)
+	}
+	return texts, track, nil
+}
+
+// joinTranslations returns texts with their translations, parallel to them,
+// on a line below. Texts without a translation are left alone.
+func joinTranslations(texts, translated []string) []string {
+	out := make([]string, len(texts))
+	for i, text := range texts {
+		out[i] = text
+		if translated[i] != "" {
+			out[i] += "\n" + translated[i]
+		}
+	}
+	return out
+}
+
+// translationCacheKey names the translations of t in the cache. Besides the
+// languages it covers whatever else determines them: the model and service
+// of the openai backend, and the file of the dictionary backend, by its
+// modification time.
+func (h *Handler) translationCacheKey(t *Translation) string {
+	key := fmt.Sprintf("%s:%s:%s", t.backend(), t.Source, t.Target)
+	switch t.backend() {
+	case translate.BackendOpenAI:
+		o := translate.NewOpenAITranslator(h.Config)
+		key += fmt.Sprintf(":%s:%s", o.Model, o.BaseURL)
+	case translate.BackendDictionary:
+		key += ":" + h.Config.TranslationDictionary
+		if info, err := os.Stat(h.Config.TranslationDictionary); err == nil {
+			key += fmt.Sprintf(":%d", info.ModTime().UnixNano())
+		}
+	}
+	return key
+}
+
+// subtitleCues times texts, parallel to segments, by the audio of the
+// segments. The intro clip of branding delays them all.
+func subtitleCues(segments []renderSegment, texts []string, branding *video.Branding) ([]video.Cue, error) {
+	intro, _, err := branding.ClipDurations()
+	if err != nil {
+		return nil, err
+	}
+
+	var cues []video.Cue
+	elapsed := intro
+	for i, seg := range segments {
+		d, err := audio.FileDuration(seg.AudioPath)
+		if err != nil {
+			return nil, err
+		}
+		cues = append(cues, video.Cue{Start: elapsed, End: elapsed + d, Text: texts[i]})
+		elapsed += d
+	}
+	return cues, nil
+}
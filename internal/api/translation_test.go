@@ -0,0 +1,243 @@
+package api
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/LeonRhapsody/pptTovideo/internal/config"
+)
+
+// dictionaryHandler returns a handler that translates with a dictionary of
+// the given entries, and the path of the dictionary.
+func dictionaryHandler(t *testing.T, entries string) (*Handler, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "dictionary.json")
+	if err := os.WriteFile(path, []byte(entries), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return &Handler{Config: &config.Config{TranslationDictionary: path}}, path
+}
+
+func TestTranslateSubtitlesCache(t *testing.T) {
+	h, dictionary := dictionaryHandler(t, `{"你好": "Hello", "谢谢": "Thank you"}`)
+	workDir := t.TempDir()
+	tr := &Translation{Backend: "dictionary", Target: "en"}
+
+	texts := []string{"你好", "", "谢谢", "你好"}
+	want := []string{"Hello", "", "Thank you", "Hello"}
+	got, err := h.translateSubtitles(tr, texts, workDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	// Mark the cached translations to tell them from fresh ones.
+	cachePath := filepath.Join(workDir, translationsFile)
+	var cache map[string]map[string]string
+	data, err := os.ReadFile(cachePath)
+	if err != nil {
+		t.Fatalf("translations were not cached: %v", err)
+	}
+	if err := json.Unmarshal(data, &cache); err != nil {
+		t.Fatal(err)
+	}
+	if len(cache) != 1 {
+		t.Fatalf("got cache %v, want one key", cache)
+	}
+	for _, known := range cache {
+		for text := range known {
+			known[text] = "cached " + known[text]
+		}
+	}
+	writeJSON(t, cachePath, cache)
+
+	got, err = h.translateSubtitles(tr, texts, workDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"cached Hello", "", "cached Thank you", "cached Hello"}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q from the cache", got, want)
+	}
+
+	// Other target languages are cached apart.
+	got, err = h.translateSubtitles(&Translation{Backend: "dictionary", Target: "ja"}, texts, workDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q for another target language, want %q", got, want)
+	}
+
+	// An edited dictionary translates again.
+	if err := os.WriteFile(dictionary, []byte(`{"你好": "Hi", "谢谢": "Thanks"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(dictionary, later, later); err != nil {
+		t.Fatal(err)
+	}
+	got, err = h.translateSubtitles(tr, texts, workDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"Hi", "", "Thanks", "Hi"}; !slices.Equal(got, want) {
+		t.Errorf("got %q after editing the dictionary, want %q", got, want)
+	}
+}
+
+func TestTranslationCacheKey(t *testing.T) {
+	h := &Handler{Config: &config.Config{OpenAIBaseURL: "https://example.com/v1"}}
+	tr := &Translation{Target: "en"}
+
+	key := h.translationCacheKey(tr)
+	h.Config.TranslationModel = "gpt-4o"
+	if h.translationCacheKey(tr) == key {
+		t.Error("changing the model kept the cache key")
+	}
+	key = h.translationCacheKey(tr)
+	h.Config.TranslationBaseURL = "https://translate.example.com/v1"
+	if h.translationCacheKey(tr) == key {
+		t.Error("changing the base URL kept the cache key")
+	}
+	key = h.translationCacheKey(tr)
+	if h.translationCacheKey(&Translation{Target: "ja"}) == key {
+		t.Error("another target language has the same cache key")
+	}
+}
+
+// writeJSON writes v to path as JSON.
+func writeJSON(t *testing.T, path string, v any) {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTranslateSubtitlesUnreadableCache(t *testing.T) {
+	h, _ := dictionaryHandler(t, `{"你好": "Hello"}`)
+	workDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workDir, translationsFile), []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := h.translateSubtitles(&Translation{Backend: "dictionary", Target: "en"}, []string{"你好"}, workDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, []string{"Hello"}) {
+		t.Errorf("got %q, want [\"Hello\"]", got)
+	}
+}
+
+func TestJoinTranslations(t *testing.T) {
+	texts := []string{"你好", "谢谢", ""}
+	got := joinTranslations(texts, []string{"Hello", "", ""})
+	want := []string{"你好\nHello", "谢谢", ""}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if texts[0] != "你好" {
+		t.Errorf("texts were changed to %q", texts)
+	}
+}
+
+// writeWAV writes a silent 16-bit mono WAV file lasting seconds.
+func writeWAV(t *testing.T, path string, seconds float64) {
+	t.Helper()
+	const rate = 8000
+	size := uint32(seconds * rate * 2)
+	header := make([]byte, 44)
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], 36+size)
+	copy(header[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(header[16:], 16)
+	binary.LittleEndian.PutUint16(header[20:], 1) // PCM
+	binary.LittleEndian.PutUint16(header[22:], 1) // Mono
+	binary.LittleEndian.PutUint32(header[24:], rate)
+	binary.LittleEndian.PutUint32(header[28:], rate*2)
+	binary.LittleEndian.PutUint16(header[32:], 2)
+	binary.LittleEndian.PutUint16(header[34:], 16)
+	copy(header[36:], "data")
+	binary.LittleEndian.PutUint32(header[40:], size)
+	if err := os.WriteFile(path, append(header, make([]byte, size)...), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// translationSegments writes the audio of segments with the given texts and
+// durations to dir.
+func translationSegments(t *testing.T, dir string, texts []string, durations []float64) []renderSegment {
+	t.Helper()
+	var segments []renderSegment
+	for i, text := range texts {
+		path := filepath.Join(dir, fmt.Sprintf("segment_%d.wav", i))
+		writeWAV(t, path, durations[i])
+		segments = append(segments, renderSegment{Text: text, AudioPath: path})
+	}
+	return segments
+}
+
+func TestApplyTranslationTrack(t *testing.T) {
+	h, _ := dictionaryHandler(t, `{"你好": "Hello", "谢谢": "Thank you"}`)
+	workDir := t.TempDir()
+	tr := &Translation{Backend: "dictionary", Target: "en", Mode: TranslationTrack}
+	texts := []string{"你好", "", "谢谢"}
+	segments := translationSegments(t, workDir, texts, []float64{1.5, 0.5, 2})
+
+	got, track, err := h.applyTranslation(tr, texts, segments, nil, workDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, texts) {
+		t.Errorf("got texts %q, want them unchanged", got)
+	}
+	if track == nil {
+		t.Fatal("no subtitle track")
+	}
+	if want := filepath.Join(workDir, "subtitles_en.srt"); track.Path != want || track.Language != "en" {
+		t.Errorf("got track %+v, want %s in en", *track, want)
+	}
+
+	data, err := os.ReadFile(track.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "1\n00:00:00,000 --> 00:00:01,500\nHello\n\n" +
+		"2\n00:00:02,000 --> 00:00:04,000\nThank you\n\n"
+	if string(data) != want {
+		t.Errorf("got SRT\n%s\nwant\n%s", data, want)
+	}
+}
+
+func TestApplyTranslationBilingual(t *testing.T) {
+	h, _ := dictionaryHandler(t, `{"你好": "Hello"}`)
+	workDir := t.TempDir()
+	tr := &Translation{Backend: "dictionary", Target: "en"}
+	texts := []string{"你好", ""}
+	segments := translationSegments(t, workDir, texts, []float64{1, 1})
+
+	got, track, err := h.applyTranslation(tr, texts, segments, nil, workDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"你好\nHello", ""}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if track != nil {
+		t.Errorf("got track %+v in bilingual mode", *track)
+	}
+	if _, err := os.Stat(filepath.Join(workDir, tr.trackFile())); err == nil {
+		t.Error("bilingual mode wrote a subtitle track")
+	}
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Extensions accepted for uploaded audio. Anything ffmpeg can decode would
+// work, but a short list keeps stray documents out of the work dir.
+var audioExtensions = []string{".mp3", ".wav", ".m4a", ".aac", ".flac", ".ogg", ".opus"}
+
+// jobWorkDir returns the work dir of an existing job. IDs that could escape
+// the uploads directory are rejected.
+func jobWorkDir(jobID string) (string, bool) {
+	if jobID == "" || jobID != filepath.Base(jobID) || strings.HasPrefix(jobID, ".") {
+		return "", false
+	}
+	workDir := filepath.Join("uploads", jobID)
+	if info, err := os.Stat(workDir); err != nil || !info.IsDir() {
+		return "", false
+	}
+	return workDir, true
+}
+
+// saveJobUpload stores the "file" form field of the request in subDir of the
+// job's work dir, keeping only the base name of the uploaded file, and returns
+// the stored file name.
+func saveJobUpload(c *gin.Context, workDir string, subDir string, allowed []string) (string, error) {
+	return saveJobUploadAs(c, workDir, subDir, allowed, "")
+}
+
+// saveJobUploadAs is saveJobUpload storing the file as base plus the uploaded
+// file's extension, unless base is empty. A nil allowed list accepts any
+// extension.
+func saveJobUploadAs(c *gin.Context, workDir string, subDir string, allowed []string, base string) (string, error) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		return "", fmt.Errorf("no file uploaded")
+	}
+
+	name := filepath.Base(file.Filename)
+	ext := strings.ToLower(filepath.Ext(name))
+	if allowed != nil && !containsString(allowed, ext) {
+		return "", fmt.Errorf("unsupported file type %q", ext)
+	}
+	if base != "" {
+		name = base + ext
+	}
+
+	dir := filepath.Join(workDir, subDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	if err := c.SaveUploadedFile(file, filepath.Join(dir, name)); err != nil {
+		return "", fmt.Errorf("failed to save file")
+	}
+	return name, nil
+}
+
+// jobUploadPath resolves a file name previously returned by saveJobUpload.
+func jobUploadPath(workDir string, subDir string, name string) (string, error) {
+	path := filepath.Join(workDir, subDir, filepath.Base(name))
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("uploaded file %q not found", name)
+	}
+	return path, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// HandleUploadMusic stores a background music track for a job.
+func (h *Handler) HandleUploadMusic(c *gin.Context) {
+	workDir, ok := jobWorkDir(c.PostForm("job_id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job expired or not found"})
+		return
+	}
+
+	name, err := saveJobUpload(c, workDir, musicDir, audioExtensions)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"file": name,
+		"url":  "/" + filepath.ToSlash(filepath.Join(workDir, musicDir, name)),
+	})
+}
@@ -0,0 +1,211 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/LeonRhapsody/pptTovideo/internal/tts"
+	"github.com/LeonRhapsody/pptTovideo/internal/usage"
+	"github.com/gin-gonic/gin"
+)
+
+// previewJobID is the job that previews are accounted to.
+const previewJobID = "preview"
+
+// UsageTotal sums the usage of one engine, day or job.
+type UsageTotal struct {
+	Key              string  `json:"key"`
+	Characters       int     `json:"characters"`
+	Requests         int     `json:"requests"`
+	FailedCharacters int     `json:"failed_characters"`
+	FailedRequests   int     `json:"failed_requests"`
+	Cost             float64 `json:"cost"`
+}
+
+// QuotaStatus reports the usage of an engine against its monthly quota.
+type QuotaStatus struct {
+	Engine    string `json:"engine"`
+	Limit     int    `json:"limit"`
+	Used      int    `json:"used"`
+	Remaining int    `json:"remaining"`
+}
+
+// UsageResponse is returned by GET /api/usage.
+type UsageResponse struct {
+	From     string         `json:"from"`
+	To       string         `json:"to"` // Exclusive
+	Currency string         `json:"currency"`
+	Total    UsageTotal     `json:"total"`
+	Engines  []UsageTotal   `json:"engines"`
+	Days     []UsageTotal   `json:"days"`
+	Jobs     []UsageTotal   `json:"jobs"`
+	Quotas   []QuotaStatus  `json:"quotas"`
+	Records  []usage.Record `json:"records"`
+}
+
+// recordUsage accounts one request to an engine, which may have failed. It is
+// the tts.Meter of the providers of a job.
+func (h *Handler) recordUsage(jobID string, engine tts.EngineType, text string, failed bool) {
+	if h.Usage == nil {
+		return
+	}
+	h.Usage.Add(jobID, string(engine), utf8.RuneCountInString(text), failed)
+}
+
+// flushUsage writes the usage recorded so far, e.g. when a render ends.
+// Failing to save it must not fail the render, so errors are only logged.
+func (h *Handler) flushUsage() {
+	if h.Usage == nil {
+		return
+	}
+	if err := h.Usage.Flush(); err != nil {
+		fmt.Printf("Warning: Failed to save TTS usage: %v\n", err)
+	}
+}
+
+// cost estimates what r was charged with the configured price of its engine.
+// Failed requests are included, so the estimate errs on the high side.
+func (h *Handler) cost(r usage.Record) float64 {
+	price := h.Config.TTSPrices[r.Engine]
+	chars, requests := r.Characters+r.FailedCharacters, r.Requests+r.FailedRequests
+	return float64(chars)/1e6*price.PerMillionChars + float64(requests)*price.PerRequest
+}
+
+// requiredCharacters counts the characters each engine would be sent to
+// narrate req in workDir, not counting fallbacks. Segments whose audio the
+// last render cached are reused and cost nothing.
+func requiredCharacters(req RenderRequest, workDir string) map[string]int {
+	required := make(map[string]int)
+	manifest := loadManifest(workDir)
+	pacing := requestPacing(req)
+	for _, seg := range buildSegments(req, "", workDir) {
+		text := strings.ReplaceAll(seg.Text, pauseMarker, "")
+		if seg.Recording != "" || strings.TrimSpace(text) == "" {
+			continue
+		}
+		if _, ok := manifest.lookup(workDir, segmentKey(seg, pacing)); ok {
+			continue
+		}
+		required[seg.Voice.EngineType] += utf8.RuneCountInString(text)
+	}
+	return required
+}
+
+// checkQuotas returns an error if rendering req in workDir would exceed the
+// monthly quota of any engine.
+func (h *Handler) checkQuotas(req RenderRequest, workDir string) error {
+	if h.Usage == nil || len(h.Config.TTSQuotas) == 0 {
+		return nil
+	}
+	now := time.Now()
+	for engine, chars := range requiredCharacters(req, workDir) {
+		limit, ok := h.Config.TTSQuotas[engine]
+		if !ok {
+			continue
+		}
+		used := h.Usage.MonthCharacters(engine, now)
+		if used+chars > limit {
+			return fmt.Errorf("monthly quota of %s exceeded: %d of %d characters used, this render needs %d", engine, used, limit, chars)
+		}
+	}
+	return nil
+}
+
+// HandleGetUsage reports TTS usage between the dates from (inclusive) and to
+// (exclusive), given as YYYY-MM-DD. It defaults to the current month.
+func (h *Handler) HandleGetUsage(c *gin.Context) {
+	if h.Usage == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Usage accounting is unavailable"})
+		return
+	}
+
+	now := time.Now()
+	from, to := usage.MonthRange(now)
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		if v := c.Query(p.name); v != "" {
+			t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid %s date: %s", p.name, v)})
+				return
+			}
+			*p.dst = t
+		}
+	}
+
+	records := h.Usage.Records(from, to)
+	resp := UsageResponse{
+		From:     from.Format("2006-01-02"),
+		To:       to.Format("2006-01-02"),
+		Currency: h.Config.PriceCurrency,
+		Total:    UsageTotal{Key: "total"},
+		Records:  records,
+	}
+	if resp.Records == nil {
+		resp.Records = []usage.Record{}
+	}
+
+	engines := make(map[string]*UsageTotal)
+	days := make(map[string]*UsageTotal)
+	jobs := make(map[string]*UsageTotal)
+	for _, r := range records {
+		cost := h.cost(r)
+		for _, t := range []*UsageTotal{
+			&resp.Total,
+			total(engines, r.Engine),
+			total(days, r.Day),
+			total(jobs, r.JobID),
+		} {
+			t.Characters += r.Characters
+			t.Requests += r.Requests
+			t.FailedCharacters += r.FailedCharacters
+			t.FailedRequests += r.FailedRequests
+			t.Cost += cost
+		}
+	}
+	resp.Engines = sortedTotals(engines)
+	resp.Days = sortedTotals(days)
+	resp.Jobs = sortedTotals(jobs)
+
+	resp.Quotas = []QuotaStatus{}
+	for engine, limit := range h.Config.TTSQuotas {
+		used := h.Usage.MonthCharacters(engine, now)
+		resp.Quotas = append(resp.Quotas, QuotaStatus{
+			Engine:    engine,
+			Limit:     limit,
+			Used:      used,
+			Remaining: max(limit-used, 0),
+		})
+	}
+	sort.Slice(resp.Quotas, func(i, j int) bool {
+		return resp.Quotas[i].Engine < resp.Quotas[j].Engine
+	})
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func total(totals map[string]*UsageTotal, key string) *UsageTotal {
+	t, ok := totals[key]
+	if !ok {
+		t = &UsageTotal{Key: key}
+		totals[key] = t
+	}
+	return t
+}
+
+func sortedTotals(totals map[string]*UsageTotal) []UsageTotal {
+	list := make([]UsageTotal, 0, len(totals))
+	for _, t := range totals {
+		list = append(list, *t)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Key < list[j].Key
+	})
+	return list
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/LeonRhapsody/pptTovideo/internal/voices"
+	"github.com/gin-gonic/gin"
+)
+
+// VoiceResponse describes an uploaded reference voice as listed to clients.
+// Voice is the value to pass as voice_name when rendering.
+type VoiceResponse struct {
+	Voice      string `json:"voice"`
+	Name       string `json:"name"`
+	Transcript string `json:"transcript"`
+	AudioURL   string `json:"audio_url"`
+}
+
+func voiceResponse(ref *voices.Reference) VoiceResponse {
+	return VoiceResponse{
+		Voice:      ref.VoiceName(),
+		Name:       ref.Name,
+		Transcript: ref.Transcript,
+		AudioURL:   "/api/voices/fishspeech/" + ref.ID + "/audio?workspace=" + ref.Workspace,
+	}
+}
+
+// requestWorkspace returns the workspace a request names in its "workspace"
+// query parameter or X-Workspace header, voices.DefaultWorkspace if neither.
+func requestWorkspace(c *gin.Context) (string, error) {
+	workspace := c.Query("workspace")
+	if workspace == "" {
+		workspace = c.GetHeader("X-Workspace")
+	}
+	if workspace == "" {
+		return voices.DefaultWorkspace, nil
+	}
+	if !voices.ValidWorkspace(workspace) {
+		return "", fmt.Errorf("invalid workspace %q", workspace)
+	}
+	return workspace, nil
+}
+
+// HandleListVoices lists the uploaded Fish Speech reference voices of a
+// workspace.
+func (h *Handler) HandleListVoices(c *gin.Context) {
+	workspace, err := requestWorkspace(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	refs, err := h.Voices.List(workspace)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	list := make([]VoiceResponse, 0, len(refs))
+	for i := range refs {
+		list = append(list, voiceResponse(&refs[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{"voices": list})
+}
+
+// HandleUploadVoice stores a reference sample and the transcript of what is
+// said in it. Fish Speech clones best from 10-30 seconds of clean speech.
+func (h *Handler) HandleUploadVoice(c *gin.Context) {
+	workspace, err := requestWorkspace(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	name := strings.TrimSpace(c.PostForm("name"))
+	transcript := strings.TrimSpace(c.PostForm("transcript"))
+	if name == "" || transcript == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name and transcript are required"})
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+		return
+	}
+	ext := strings.ToLower(filepath.Ext(file.Filename))
+	if !containsString(audioExtensions, ext) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported audio format " + ext})
+		return
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read upload"})
+		return
+	}
+	defer src.Close()
+
+	ref, err := h.Voices.Add(workspace, name, transcript, src, ext)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save voice: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, voiceResponse(ref))
+}
+
+// HandleVoiceAudio serves the sample of a reference voice.
+func (h *Handler) HandleVoiceAudio(c *gin.Context) {
+	workspace, err := requestWorkspace(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ref, err := h.Voices.Get(workspace, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.File(h.Voices.AudioPath(ref))
+}
+
+// HandleDeleteVoice removes a reference voice.
+func (h *Handler) HandleDeleteVoice(c *gin.Context) {
+	workspace, err := requestWorkspace(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.Voices.Delete(workspace, c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Voice deleted"})
+}
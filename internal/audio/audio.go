@@ -0,0 +1,71 @@
+package audio
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// SampleRate and ChannelLayout describe the common format every stitched file
+// is resampled to, so inputs from different engines can be joined.
+const (
+	SampleRate    = 48000
+	ChannelLayout = "mono"
+)
+
+// Concat joins the input audio files, in order, into output.
+// Each input is decoded and resampled before joining, so the inputs may use
+// different codecs or sample rates and encoder padding is not carried over.
+// The output codec is chosen by ffmpeg from the output file extension.
+func Concat(inputs []string, output string) error {
+	if len(inputs) == 0 {
+		return fmt.Errorf("no audio inputs to concatenate")
+	}
+
+	var args []string
+	for _, in := range inputs {
+		args = append(args, "-i", in)
+	}
+
+	var filter strings.Builder
+	for i := range inputs {
+		fmt.Fprintf(&filter, "[%d:a]aresample=%d,aformat=sample_fmts=fltp:channel_layouts=%s[a%d];", i, SampleRate, ChannelLayout, i)
+	}
+	for i := range inputs {
+		fmt.Fprintf(&filter, "[a%d]", i)
+	}
+	fmt.Fprintf(&filter, "concat=n=%d:v=0:a=1[out]", len(inputs))
+
+	args = append(args, "-filter_complex", filter.String(), "-map", "[out]", "-y", output)
+	return run(args...)
+}
+
+// Cut writes the part of in from start to end, in seconds, to out,
+// resampled to the common format. An end of zero or less cuts to the end of in.
+func Cut(in string, out string, start float64, end float64) error {
+	filter := "atrim=start=" + formatSeconds(start)
+	if end > 0 {
+		filter += ":end=" + formatSeconds(end)
+	}
+	filter += fmt.Sprintf(",asetpts=PTS-STARTPTS,aresample=%d,aformat=channel_layouts=%s", SampleRate, ChannelLayout)
+	return run("-i", in, "-af", filter, "-vn", "-y", out)
+}
+
+// Duration returns the duration of a media file in seconds.
+func Duration(path string) (float64, error) {
+	cmd := exec.Command("ffprobe", "-v", "error", "-show_entries", "format=duration", "-of", "default=noprint_wrappers=1:nokey=1", path)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe failed: %w, output: %s", err, string(out))
+	}
+	return strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+}
+
+func run(args ...string) error {
+	cmd := exec.Command("ffmpeg", append([]string{"-hide_banner", "-nostdin"}, args...)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("ffmpeg failed: %w, output: %s", err, string(out))
+	}
+	return nil
+}
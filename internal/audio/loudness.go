@@ -0,0 +1,155 @@
+package audio
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// LoudnessTarget is the EBU R128 target of loudness normalization.
+type LoudnessTarget struct {
+	IntegratedLUFS float64 `json:"target_lufs"` // e.g. -16 for online video, -23 for broadcast
+	TruePeak       float64 `json:"true_peak"`   // Maximum true peak in dBTP
+	LRA            float64 `json:"lra"`         // Loudness range target in LU
+}
+
+// DefaultLoudnessTarget suits narration played back online.
+var DefaultLoudnessTarget = LoudnessTarget{IntegratedLUFS: -16, TruePeak: -1.5, LRA: 11}
+
+// WithDefaults fills unset fields from DefaultLoudnessTarget.
+func (t LoudnessTarget) WithDefaults() LoudnessTarget {
+	if t.IntegratedLUFS == 0 {
+		t.IntegratedLUFS = DefaultLoudnessTarget.IntegratedLUFS
+	}
+	if t.TruePeak == 0 {
+		t.TruePeak = DefaultLoudnessTarget.TruePeak
+	}
+	if t.LRA == 0 {
+		t.LRA = DefaultLoudnessTarget.LRA
+	}
+	return t
+}
+
+// Loudness holds the EBU R128 measurements of a file.
+type Loudness struct {
+	IntegratedLUFS float64 `json:"integrated_lufs"`
+	TruePeak       float64 `json:"true_peak"`
+	LRA            float64 `json:"lra"`
+	Threshold      float64 `json:"threshold"`
+}
+
+// NormalizeReport holds the loudness of a file before and after normalization.
+type NormalizeReport struct {
+	Input  Loudness `json:"input"`
+	Output Loudness `json:"output"`
+}
+
+// loudnormStats is the JSON that loudnorm prints with print_format=json.
+// All values are printed as strings, and may be "-inf" for silence.
+type loudnormStats struct {
+	InputI       string `json:"input_i"`
+	InputTP      string `json:"input_tp"`
+	InputLRA     string `json:"input_lra"`
+	InputThresh  string `json:"input_thresh"`
+	OutputI      string `json:"output_i"`
+	OutputTP     string `json:"output_tp"`
+	OutputLRA    string `json:"output_lra"`
+	OutputThresh string `json:"output_thresh"`
+	TargetOffset string `json:"target_offset"`
+}
+
+// ErrSilent is returned by Normalize for input without measurable loudness.
+var ErrSilent = errors.New("audio is silent")
+
+// Normalize applies two-pass loudnorm to in and writes the result to out.
+// The first pass measures the input so that the second pass can apply a
+// linear gain where possible instead of dynamic compression. Silent input
+// cannot be normalized and yields ErrSilent without writing out.
+func Normalize(in string, out string, target LoudnessTarget) (NormalizeReport, error) {
+	target = target.WithDefaults()
+
+	first, err := loudnorm(in, "", target, nil)
+	if err != nil {
+		return NormalizeReport{}, err
+	}
+	measured, err := first.input()
+	if err != nil {
+		return NormalizeReport{}, err
+	}
+
+	second, err := loudnorm(in, out, target, first)
+	if err != nil {
+		return NormalizeReport{}, err
+	}
+	normalized, err := second.output()
+	if err != nil {
+		return NormalizeReport{}, err
+	}
+	return NormalizeReport{Input: measured, Output: normalized}, nil
+}
+
+// loudnorm runs one loudnorm pass. Without measured stats it only analyzes
+// the input; with them it writes the normalized audio to out.
+func loudnorm(in string, out string, target LoudnessTarget, measured *loudnormStats) (*loudnormStats, error) {
+	filter := fmt.Sprintf("loudnorm=I=%g:TP=%g:LRA=%g:print_format=json", target.IntegratedLUFS, target.TruePeak, target.LRA)
+	if measured != nil {
+		filter += fmt.Sprintf(":measured_I=%s:measured_TP=%s:measured_LRA=%s:measured_thresh=%s:offset=%s:linear=true",
+			measured.InputI, measured.InputTP, measured.InputLRA, measured.InputThresh, measured.TargetOffset)
+		// loudnorm upsamples to 192kHz internally; bring it back down.
+		filter += fmt.Sprintf(",aresample=%d", SampleRate)
+	}
+
+	args := []string{"-hide_banner", "-nostdin", "-i", in, "-af", filter}
+	if out == "" {
+		args = append(args, "-f", "null", "-")
+	} else {
+		args = append(args, "-y", out)
+	}
+
+	output, err := exec.Command("ffmpeg", args...).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("loudnorm failed: %w, output: %s", err, string(output))
+	}
+
+	// The stats are the last JSON object in the log.
+	log := string(output)
+	start, end := strings.LastIndex(log, "{"), strings.LastIndex(log, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("loudnorm printed no stats")
+	}
+	var stats loudnormStats
+	if err := json.Unmarshal([]byte(log[start:end+1]), &stats); err != nil {
+		return nil, fmt.Errorf("parsing loudnorm stats: %w", err)
+	}
+	return &stats, nil
+}
+
+func (s *loudnormStats) input() (Loudness, error) {
+	return parseLoudness(s.InputI, s.InputTP, s.InputLRA, s.InputThresh)
+}
+
+func (s *loudnormStats) output() (Loudness, error) {
+	return parseLoudness(s.OutputI, s.OutputTP, s.OutputLRA, s.OutputThresh)
+}
+
+func parseLoudness(i, tp, lra, thresh string) (Loudness, error) {
+	var l Loudness
+	var err error
+	for _, f := range []struct {
+		dst *float64
+		val string
+	}{{&l.IntegratedLUFS, i}, {&l.TruePeak, tp}, {&l.LRA, lra}, {&l.Threshold, thresh}} {
+		if *f.dst, err = strconv.ParseFloat(strings.TrimSpace(f.val), 64); err != nil {
+			return Loudness{}, fmt.Errorf("invalid loudness value %q: %w", f.val, err)
+		}
+		// loudnorm reports "-inf" for digital silence.
+		if math.IsInf(*f.dst, 0) || math.IsNaN(*f.dst) {
+			return Loudness{}, ErrSilent
+		}
+	}
+	return l, nil
+}
@@ -0,0 +1,107 @@
+package audio
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+)
+
+// Silence detection settings. Engines fade in and out of their noise floor,
+// so a little silence is kept on each side to avoid clipping the first and
+// last phonemes.
+const (
+	silenceThreshold = "-50dB"
+	silenceMinimum   = 0.05 // Seconds of silence before it is detected
+	silenceKeep      = 0.05 // Seconds of silence kept at each edge
+)
+
+// Silence writes duration seconds of digital silence to out.
+func Silence(duration float64, out string) error {
+	return run("-f", "lavfi", "-i", fmt.Sprintf("anullsrc=r=%d:cl=%s", SampleRate, ChannelLayout),
+		"-t", formatSeconds(duration), "-y", out)
+}
+
+// PadEnd appends duration seconds of silence to in and writes it to out.
+func PadEnd(in string, out string, duration float64) error {
+	return run("-i", in, "-af", fmt.Sprintf("aresample=%d,apad=pad_dur=%s", SampleRate, formatSeconds(duration)), "-y", out)
+}
+
+// TrimResult describes the part of the input that TrimSilence kept.
+type TrimResult struct {
+	Start    float64 // Seconds cut from the start of the input
+	Duration float64 // Duration of the output
+}
+
+// TrimSilence removes leading and trailing silence from in and writes the
+// result to out. The returned Start lets callers shift timings that were
+// measured against the untrimmed audio.
+func TrimSilence(in string, out string) (TrimResult, error) {
+	total, err := Duration(in)
+	if err != nil {
+		return TrimResult{}, err
+	}
+
+	start, end, err := detectEdges(in, total)
+	if err != nil {
+		return TrimResult{}, err
+	}
+	start = max(0, start-silenceKeep)
+	end = min(total, end+silenceKeep)
+	if end <= start {
+		// Nothing but silence; keep it as is rather than producing nothing.
+		start, end = 0, total
+	}
+
+	err = run("-i", in, "-af", fmt.Sprintf("atrim=start=%s:end=%s,asetpts=PTS-STARTPTS,aresample=%d", formatSeconds(start), formatSeconds(end), SampleRate), "-y", out)
+	if err != nil {
+		return TrimResult{}, err
+	}
+	return TrimResult{Start: start, Duration: end - start}, nil
+}
+
+var (
+	silenceStartPattern = regexp.MustCompile(`silence_start: (-?[\d.]+)`)
+	silenceEndPattern   = regexp.MustCompile(`silence_end: (-?[\d.]+)`)
+)
+
+// detectEdges returns where the sound in path starts and ends, in seconds.
+func detectEdges(path string, total float64) (float64, float64, error) {
+	cmd := exec.Command("ffmpeg", "-hide_banner", "-nostdin", "-i", path,
+		"-af", fmt.Sprintf("silencedetect=noise=%s:d=%g", silenceThreshold, silenceMinimum),
+		"-f", "null", "-")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return 0, 0, fmt.Errorf("silencedetect failed: %w, output: %s", err, string(output))
+	}
+
+	starts := parseSeconds(silenceStartPattern, output)
+	ends := parseSeconds(silenceEndPattern, output)
+
+	soundStart, soundEnd := 0.0, total
+	// Leading silence: the first silence starts at the very beginning.
+	if len(starts) > 0 && starts[0] <= silenceMinimum && len(ends) > 0 {
+		soundStart = ends[0]
+	}
+	// Trailing silence: the last silence either never ends or ends with the file.
+	if n := len(starts); n > 0 && starts[n-1] > soundStart {
+		if len(ends) < n || ends[n-1] >= total-silenceMinimum {
+			soundEnd = starts[n-1]
+		}
+	}
+	return soundStart, soundEnd, nil
+}
+
+func parseSeconds(pattern *regexp.Regexp, output []byte) []float64 {
+	var values []float64
+	for _, m := range pattern.FindAllSubmatch(output, -1) {
+		if v, err := strconv.ParseFloat(string(m[1]), 64); err == nil {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func formatSeconds(s float64) string {
+	return strconv.FormatFloat(s, 'f', 3, 64)
+}
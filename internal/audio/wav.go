@@ -0,0 +1,86 @@
+package audio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileDuration returns the duration of an audio file in seconds. PCM WAV files,
+// which every stage of the pipeline writes, are measured from their header;
+// anything else is probed with ffprobe.
+func FileDuration(path string) (float64, error) {
+	if strings.EqualFold(filepath.Ext(path), ".wav") {
+		if d, err := WAVDuration(path); err == nil {
+			return d, nil
+		}
+	}
+	return Duration(path)
+}
+
+// WAVDuration returns the duration of a PCM WAV file computed from the size of
+// its data chunk, which is exact to the sample and needs no subprocess.
+func WAVDuration(path string) (float64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var riff [12]byte
+	if _, err := io.ReadFull(f, riff[:]); err != nil {
+		return 0, err
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return 0, fmt.Errorf("%s is not a WAV file", path)
+	}
+
+	var byteRate uint32
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(f, header[:]); err != nil {
+			return 0, fmt.Errorf("%s has no data chunk", path)
+		}
+		id, size := string(header[0:4]), binary.LittleEndian.Uint32(header[4:8])
+
+		switch id {
+		case "fmt ":
+			var format [16]byte
+			if size < 16 {
+				return 0, fmt.Errorf("%s has a short fmt chunk", path)
+			}
+			if _, err := io.ReadFull(f, format[:]); err != nil {
+				return 0, err
+			}
+			byteRate = binary.LittleEndian.Uint32(format[8:12])
+			if _, err := f.Seek(int64(size-16+size%2), io.SeekCurrent); err != nil {
+				return 0, err
+			}
+		case "data":
+			if byteRate == 0 {
+				return 0, fmt.Errorf("%s has no fmt chunk before its data", path)
+			}
+			// Streamed output leaves the size unset; fall back to the file size.
+			if size == 0 || size == 0xFFFFFFFF {
+				pos, err := f.Seek(0, io.SeekCurrent)
+				if err != nil {
+					return 0, err
+				}
+				info, err := f.Stat()
+				if err != nil {
+					return 0, err
+				}
+				size = uint32(info.Size() - pos)
+			}
+			return float64(size) / float64(byteRate), nil
+		default:
+			// Chunks are padded to an even size.
+			if _, err := f.Seek(int64(size+size%2), io.SeekCurrent); err != nil {
+				return 0, err
+			}
+		}
+	}
+}
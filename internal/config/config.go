@@ -2,7 +2,10 @@ package config
 
 import (
 	"encoding/json"
+	"maps"
 	"os"
+	"slices"
+	"strings"
 	"sync"
 )
 
@@ -24,20 +27,364 @@ type Config struct {
 	OpenAIAPIKey  string `json:"openai_api_key"`
 	OpenAIBaseURL string `json:"openai_base_url"` // Optional proxy
 
+	// Translation of subtitles. The "openai" backend works with any
+	// OpenAI-compatible chat API; its key and URL default to the OpenAI ones.
+	TranslationAPIKey  string `json:"translation_api_key"`
+	TranslationBaseURL string `json:"translation_base_url"`
+	TranslationModel   string `json:"translation_model"` // Default gpt-4o-mini
+	// TranslationDictionary is a JSON file of phrases and their translations
+	// used by the "dictionary" backend.
+	TranslationDictionary string `json:"translation_dictionary"`
+
 	// Fish Speech
 	FishSpeechAPIKey string `json:"fish_speech_api_key"`
 	FishSpeechAPIURL string `json:"fish_speech_api_url"` // e.g., https://api.fish.audio/v1/tts
 
+	// VoiceDir holds uploaded reference voices for cloning, one directory per
+	// workspace.
+	VoiceDir string `json:"voice_dir"`
+
+	// FontDir holds uploaded fonts, which subtitles use alongside the
+	// installed ones.
+	FontDir string `json:"font_dir"`
+
+	// TTSFallbacks is tried in order when the selected engine fails a segment.
+	TTSFallbacks []TTSFallback `json:"tts_fallbacks"`
+
+	// CustomEngines are template-driven engines registered under their own name.
+	CustomEngines []CustomEngine `json:"custom_engines"`
+
+	// UsageFile stores TTS usage records for accounting.
+	UsageFile string `json:"usage_file"`
+	// TTSPrices maps engine names to their price, used for cost estimates.
+	TTSPrices     map[string]TTSPrice `json:"tts_prices"`
+	PriceCurrency string              `json:"price_currency"`
+	// TTSQuotas maps engine names to a monthly character limit. Renders that
+	// would exceed it are refused before they start.
+	TTSQuotas map[string]int `json:"tts_quotas"`
+
+	// EncodingProfiles add to the built-in profiles, or replace those with the
+	// same name. Renders select a profile by name.
+	EncodingProfiles []EncodingProfile `json:"encoding_profiles"`
+
+	// SubtitlePresets are saved subtitle styles, added to or replacing the
+	// built-in presets of the same name.
+	SubtitlePresets []SubtitlePreset `json:"subtitle_presets"`
+
 	Port string `json:"port"`
 
 	mu sync.RWMutex
 }
 
+// TTSFallback names an engine and voice to fall back to.
+type TTSFallback struct {
+	Engine string `json:"engine"`
+	Voice  string `json:"voice"`
+}
+
+// CustomEngine describes a self-hosted TTS engine that is called through an
+// HTTP request template or a command template instead of Go code. Templates
+// use Go text/template syntax and see .Text, .Voice, .Rate, .Volume, .Pitch
+// and .Speed (the rate as a multiplier, 1.0 by default).
+type CustomEngine struct {
+	Name   string   `json:"name"`  // Engine type used in requests, e.g. "cosyvoice"
+	Label  string   `json:"label"` // Display name, defaults to Name
+	Voices []string `json:"voices"`
+
+	// Exactly one of HTTP or Command must be set.
+	HTTP *HTTPTemplate `json:"http,omitempty"`
+	// Command is the argv of a program to run, without a shell. Besides the
+	// common fields it sees .TextFile, a file holding the text, and .Output,
+	// the file to write. If the program leaves .Output empty, its stdout is
+	// taken as the audio.
+	Command []string `json:"command,omitempty"`
+
+	AudioFormat string `json:"audio_format"` // "mp3", "wav", ... or "pcm" for raw s16le mono
+	SampleRate  int    `json:"sample_rate"`  // Sample rate of "pcm" audio
+	MaxInput    int    `json:"max_input"`    // Max characters per request, 0 for no limit
+	Timeout     int    `json:"timeout"`      // Seconds, default 60
+}
+
+// HTTPTemplate is the request sent for every synthesis. Values substituted
+// into URL are query-escaped and values substituted into Body are escaped
+// for use inside a JSON string, e.g. {"text": "{{.Text}}"}.
+type HTTPTemplate struct {
+	URL     string            `json:"url"`
+	Method  string            `json:"method"` // Default POST
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+
+	// AudioField is the dot-separated path of base64 audio in a JSON
+	// response, e.g. "data.audio". Empty means the body is the audio.
+	AudioField string `json:"audio_field"`
+}
+
+// Fallbacks returns the fallback voices.
+func (c *Config) Fallbacks() []TTSFallback {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return slices.Clone(c.TTSFallbacks)
+}
+
+// SetFallbacks replaces the fallback voices, which renders use from then on.
+// Call Save to persist them.
+func (c *Config) SetFallbacks(fallbacks []TTSFallback) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.TTSFallbacks = fallbacks
+}
+
+// Engines returns the custom engines.
+func (c *Config) Engines() []CustomEngine {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return slices.Clone(c.CustomEngines)
+}
+
+// SetCustomEngines replaces the custom engines, which renders use from then
+// on. Call Save to persist them.
+func (c *Config) SetCustomEngines(engines []CustomEngine) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.CustomEngines = engines
+}
+
+// CustomEngine returns the custom engine registered under name.
+func (c *Config) CustomEngine(name string) (CustomEngine, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, e := range c.CustomEngines {
+		if e.Name == name {
+			return e, true
+		}
+	}
+	return CustomEngine{}, false
+}
+
+// TTSPrice is what an engine charges. Both parts are added up.
+type TTSPrice struct {
+	PerMillionChars float64 `json:"per_million_chars"`
+	PerRequest      float64 `json:"per_request"`
+}
+
+// defaultTTSPrices are the published list prices of the paid engines at
+// their default models. Override them in config to match your contract.
+var defaultTTSPrices = map[string]TTSPrice{
+	"openai": {PerMillionChars: 15},
+	"google": {PerMillionChars: 16},
+	// Xunfei bills requests from prepaid packages rather than characters;
+	// this is an estimate, set the price of your package instead.
+	"xunfei": {PerRequest: 0.0004},
+}
+
+// EncodingProfile describes how a render is encoded. Zero fields take the
+// defaults noted.
+type EncodingProfile struct {
+	Name  string `json:"name"`
+	Label string `json:"label"` // Display name, defaults to Name
+
+	// Resolution is the short side of the video in pixels; 0 keeps the size
+	// of the slides, or 1080 for canvas presets.
+	Resolution int    `json:"resolution"`
+	FPS        int    `json:"fps"`         // Default 25
+	VideoCodec string `json:"video_codec"` // ffmpeg encoder, default libx264
+	// CRF selects constant quality encoding; otherwise Bitrate, e.g. "5M",
+	// is the average video bitrate.
+	CRF     int    `json:"crf"`
+	Bitrate string `json:"bitrate"`
+	Preset  string `json:"preset"` // Encoder preset such as "veryfast" or "slow"
+	GOP     int    `json:"gop"`    // Frames between keyframes, 0 for the encoder default
+	// Tune is passed to the encoder as -tune, or "none" for no tuning. By
+	// default libx264 is tuned for still images when nothing on the video
+	// moves.
+	Tune string `json:"tune"`
+
+	AudioCodec   string `json:"audio_codec"`   // Default aac
+	AudioBitrate string `json:"audio_bitrate"` // Default 192k
+
+	// DisableFastStart leaves the index at the end of MP4 files, which saves
+	// a pass over the file but delays playback of progressive downloads.
+	DisableFastStart bool `json:"disable_faststart"`
+
+	// TargetSizeMB overrides CRF and Bitrate with the bitrate that makes the
+	// file about this large, computed from the length of the video.
+	TargetSizeMB float64 `json:"target_size_mb"`
+}
+
+// BuiltinEncodingProfiles are available without configuration. Their names
+// are the former fixed qualities.
+var BuiltinEncodingProfiles = []EncodingProfile{
+	{Name: "720p", Label: "720P", Resolution: 720, Bitrate: "2.5M"},
+	{Name: "1080p", Label: "1080P", Resolution: 1080, Bitrate: "5M"},
+	{Name: "4k", Label: "4K", Resolution: 2160, Bitrate: "15M"},
+}
+
+// DefaultEncodingProfile is used by renders that do not name a profile.
+const DefaultEncodingProfile = "1080p"
+
+// Profiles returns the built-in and configured encoding profiles, with
+// configured ones replacing built-in ones of the same name.
+func (c *Config) Profiles() []EncodingProfile {
+	var profiles []EncodingProfile
+	for _, p := range BuiltinEncodingProfiles {
+		if _, ok := c.configuredProfile(p.Name); !ok {
+			profiles = append(profiles, p)
+		}
+	}
+	return append(profiles, c.EncodingProfiles...)
+}
+
+// EncodingProfile returns the profile called name, case-insensitively.
+func (c *Config) EncodingProfile(name string) (EncodingProfile, bool) {
+	if p, ok := c.configuredProfile(name); ok {
+		return p, true
+	}
+	for _, p := range BuiltinEncodingProfiles {
+		if strings.EqualFold(p.Name, name) {
+			return p, true
+		}
+	}
+	return EncodingProfile{}, false
+}
+
+func (c *Config) configuredProfile(name string) (EncodingProfile, bool) {
+	for _, p := range c.EncodingProfiles {
+		if strings.EqualFold(p.Name, name) {
+			return p, true
+		}
+	}
+	return EncodingProfile{}, false
+}
+
+// Subtitle positions.
+const (
+	SubtitleBottom = "bottom"
+	SubtitleTop    = "top"
+	SubtitleCustom = "custom" // At SubtitleStyle.Y
+)
+
+// SubtitleStyle describes how subtitles are drawn. Sizes are in pixels of
+// the video; zero fields take the defaults noted.
+type SubtitleStyle struct {
+	// FontFamily picks the font by name, e.g. "Noto Sans CJK"; empty for the
+	// best available CJK font.
+	FontFamily string `json:"font_family"`
+	FontSize   int    `json:"font_size"` // Default 48
+	// Colors are "#RRGGBB" or "#RRGGBBAA".
+	Color        string `json:"color"`         // Default white
+	OutlineColor string `json:"outline_color"` // Default black
+	OutlineWidth int    `json:"outline_width"` // 0 for no outline
+	// Background is the color of a box behind the text, usually
+	// semi-transparent such as "#00000099"; empty for none.
+	Background string `json:"background"`
+
+	Position string  `json:"position"` // SubtitleBottom (default), SubtitleTop or SubtitleCustom
+	Y        float64 `json:"y"`        // Center of custom subtitles, relative to the height
+	// MarginV is the distance from the top or bottom edge, default 50.
+	// MarginH is the distance from the side edges, default 5% of the width.
+	MarginV int `json:"margin_v"`
+	MarginH int `json:"margin_h"`
+
+	MaxLines   int     `json:"max_lines"`   // Longer text is cut off with an ellipsis; 0 for no limit
+	Align      string  `json:"align"`       // "center" (default), "left" or "right"
+	LineHeight float64 `json:"line_height"` // Relative to the font size, default 1.5
+}
+
+// SubtitlePreset is a named subtitle style.
+type SubtitlePreset struct {
+	Name  string        `json:"name"`
+	Label string        `json:"label"` // Display name, defaults to Name
+	Style SubtitleStyle `json:"style"`
+}
+
+// BuiltinSubtitlePresets are available without configuration.
+var BuiltinSubtitlePresets = []SubtitlePreset{
+	{Name: "default", Label: "经典描边", Style: SubtitleStyle{OutlineWidth: 2}},
+	{Name: "boxed", Label: "半透明底框", Style: SubtitleStyle{Background: "#00000099", MaxLines: 2}},
+	{Name: "top", Label: "顶部字幕", Style: SubtitleStyle{OutlineWidth: 2, Position: SubtitleTop}},
+}
+
+// DefaultSubtitlePreset is used by renders that set neither a style nor a
+// preset.
+const DefaultSubtitlePreset = "default"
+
+// Presets returns the built-in and saved subtitle presets, with saved ones
+// replacing built-in ones of the same name.
+func (c *Config) Presets() []SubtitlePreset {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var presets []SubtitlePreset
+	for _, p := range BuiltinSubtitlePresets {
+		if _, ok := c.savedPreset(p.Name); !ok {
+			presets = append(presets, p)
+		}
+	}
+	return append(presets, c.SubtitlePresets...)
+}
+
+// SubtitlePreset returns the preset called name, case-insensitively.
+func (c *Config) SubtitlePreset(name string) (SubtitlePreset, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if i, ok := c.savedPreset(name); ok {
+		return c.SubtitlePresets[i], true
+	}
+	for _, p := range BuiltinSubtitlePresets {
+		if strings.EqualFold(p.Name, name) {
+			return p, true
+		}
+	}
+	return SubtitlePreset{}, false
+}
+
+// SetSubtitlePreset saves p, replacing the saved preset of the same name.
+// Call Save to persist it.
+func (c *Config) SetSubtitlePreset(p SubtitlePreset) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if i, ok := c.savedPreset(p.Name); ok {
+		c.SubtitlePresets[i] = p
+	} else {
+		c.SubtitlePresets = append(c.SubtitlePresets, p)
+	}
+}
+
+// DeleteSubtitlePreset removes the saved preset called name and reports
+// whether there was one. Built-in presets cannot be removed.
+func (c *Config) DeleteSubtitlePreset(name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	i, ok := c.savedPreset(name)
+	if ok {
+		c.SubtitlePresets = append(c.SubtitlePresets[:i], c.SubtitlePresets[i+1:]...)
+	}
+	return ok
+}
+
+func (c *Config) savedPreset(name string) (int, bool) {
+	for i, p := range c.SubtitlePresets {
+		if strings.EqualFold(p.Name, name) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
 const ConfigFile = "config.json"
 
 func LoadConfig() *Config {
 	cfg := &Config{
-		Port: "8080",
+		Port:          "8080",
+		VoiceDir:      "voices",
+		FontDir:       "fonts",
+		UsageFile:     "data/usage.json",
+		TTSPrices:     maps.Clone(defaultTTSPrices),
+		PriceCurrency: "USD",
 	}
 
 	// Try loading from file first
@@ -12,6 +12,7 @@ import (
 
 type Slide struct {
 	Index     int
+	Title     string // Text of the title placeholder, if any
 	Note      string
 	ImagePath string
 }
@@ -74,10 +75,16 @@ func ParsePPT(pptxPath string) ([]Slide, error) {
 			noteText = "No notes for this slide."
 		}
 
+		title, errTitle := extractTitle(r, filepath.ToSlash(filepath.Join("ppt", target)))
+		if errTitle != nil {
+			fmt.Printf("Warning: failed to extract title of slide %d: %v\n", i+1, errTitle)
+		}
+
 		fmt.Printf("Debug: Slide %d (rId %s) notes length: %d\n", i+1, rId, len(noteText))
 
 		slides = append(slides, Slide{
 			Index: i + 1,
+			Title: title,
 			Note:  noteText,
 		})
 	}
@@ -251,6 +258,65 @@ func extractTextFromXML(r *zip.ReadCloser, path string) (string, error) {
 	return strings.TrimSpace(fullTextBuilder.String()), nil
 }
 
+// extractTitle returns the text of the title placeholder of a slide, with
+// line breaks replaced by spaces.
+func extractTitle(r *zip.ReadCloser, path string) (string, error) {
+	f := findFile(r, path)
+	if f == nil {
+		return "", fmt.Errorf("file not found: %s", path)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	decoder := xml.NewDecoder(rc)
+	var parts []string
+	var inTitle, inText bool
+
+	for {
+		t, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		switch se := t.(type) {
+		case xml.StartElement:
+			if se.Name.Local == "sp" {
+				inTitle = false
+			}
+			if se.Name.Local == "ph" {
+				for _, attr := range se.Attr {
+					if attr.Name.Local == "type" && (attr.Value == "title" || attr.Value == "ctrTitle") {
+						inTitle = true
+					}
+				}
+			}
+			if se.Name.Local == "t" && inTitle {
+				inText = true
+			}
+		case xml.CharData:
+			if inText {
+				parts = append(parts, string(se))
+			}
+		case xml.EndElement:
+			if se.Name.Local == "t" {
+				inText = false
+			}
+			if se.Name.Local == "p" && inTitle {
+				parts = append(parts, " ")
+			}
+			if se.Name.Local == "sp" {
+				if inTitle {
+					return strings.Join(strings.Fields(strings.Join(parts, "")), " "), nil
+				}
+				inTitle = false
+			}
+		}
+	}
+	return strings.Join(strings.Fields(strings.Join(parts, "")), " "), nil
+}
+
 func findFile(r *zip.ReadCloser, name string) *zip.File {
 	for _, f := range r.File {
 		if f.Name == name {
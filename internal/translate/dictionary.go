@@ -0,0 +1,65 @@
+package translate
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// DictionaryTranslator translates from a fixed list of phrases, whatever the
+// languages asked for. Texts found in the list as a whole are replaced by
+// their translation; in other texts the phrases of the list are replaced,
+// longest first, and the rest is kept.
+type DictionaryTranslator struct {
+	Entries  map[string]string
+	replacer *strings.Replacer
+}
+
+// NewDictionaryTranslator returns a translator for the given phrases.
+func NewDictionaryTranslator(entries map[string]string) *DictionaryTranslator {
+	var phrases []string
+	for phrase := range entries {
+		if phrase != "" {
+			phrases = append(phrases, phrase)
+		}
+	}
+	// The replacer prefers the phrases that come first.
+	slices.SortFunc(phrases, func(a, b string) int {
+		if n := len(b) - len(a); n != 0 {
+			return n
+		}
+		return strings.Compare(a, b)
+	})
+	pairs := make([]string, 0, 2*len(phrases))
+	for _, phrase := range phrases {
+		pairs = append(pairs, phrase, entries[phrase])
+	}
+	return &DictionaryTranslator{Entries: entries, replacer: strings.NewReplacer(pairs...)}
+}
+
+// LoadDictionary reads a JSON object mapping phrases to their translations.
+func LoadDictionary(path string) (*DictionaryTranslator, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries map[string]string
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse translation dictionary %s: %v", path, err)
+	}
+	return NewDictionaryTranslator(entries), nil
+}
+
+func (t *DictionaryTranslator) Translate(texts []string, source, target string) ([]string, error) {
+	out := make([]string, len(texts))
+	for i, text := range texts {
+		if translation, ok := t.Entries[strings.TrimSpace(text)]; ok {
+			out[i] = translation
+			continue
+		}
+		out[i] = t.replacer.Replace(text)
+	}
+	return out, nil
+}
@@ -0,0 +1,149 @@
+package translate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/LeonRhapsody/pptTovideo/internal/config"
+)
+
+// batchSize is how many texts are sent in one request. Subtitles are short,
+// and batches give the model the context of neighboring lines.
+const batchSize = 40
+
+// OpenAITranslator translates through the chat completions endpoint of
+// OpenAI or a compatible service.
+type OpenAITranslator struct {
+	APIKey  string
+	BaseURL string
+	Model   string
+	Client  *http.Client
+}
+
+// NewOpenAITranslator returns a translator using the translation settings of
+// cfg, which default to its OpenAI settings.
+func NewOpenAITranslator(cfg *config.Config) *OpenAITranslator {
+	t := &OpenAITranslator{
+		APIKey:  cfg.TranslationAPIKey,
+		BaseURL: cfg.TranslationBaseURL,
+		Model:   cfg.TranslationModel,
+		Client:  &http.Client{Timeout: 120 * time.Second},
+	}
+	if t.APIKey == "" {
+		t.APIKey = cfg.OpenAIAPIKey
+	}
+	if t.BaseURL == "" {
+		t.BaseURL = cfg.OpenAIBaseURL
+	}
+	if t.BaseURL == "" {
+		t.BaseURL = "https://api.openai.com/v1"
+	}
+	if t.Model == "" {
+		t.Model = "gpt-4o-mini"
+	}
+	return t
+}
+
+func (t *OpenAITranslator) Translate(texts []string, source, target string) ([]string, error) {
+	if t.APIKey == "" {
+		return nil, fmt.Errorf("translation API key not configured")
+	}
+
+	var out []string
+	for start := 0; start < len(texts); start += batchSize {
+		batch := texts[start:min(start+batchSize, len(texts))]
+		translated, err := t.translateBatch(batch, source, target)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, translated...)
+	}
+	return out, nil
+}
+
+func (t *OpenAITranslator) translateBatch(texts []string, source, target string) ([]string, error) {
+	from := source
+	if from == "" {
+		from = "the language they are in"
+	}
+	prompt := fmt.Sprintf("You translate video subtitles from %s into %s. "+
+		"The user sends a JSON array of subtitle lines. Translate every line so that it reads well as a subtitle, "+
+		"keeping numbers, names and product names. Answer with only a JSON array of the translations, "+
+		"in the same order and with the same number of elements.", from, target)
+
+	input, err := json.Marshal(texts)
+	if err != nil {
+		return nil, err
+	}
+	reqBody := map[string]interface{}{
+		"model": t.Model,
+		"messages": []map[string]string{
+			{"role": "system", "content": prompt},
+			{"role": "user", "content": string(input)},
+		},
+		"temperature": 0.2,
+	}
+	jsonData, _ := json.Marshal(reqBody)
+
+	req, err := http.NewRequest("POST", strings.TrimSuffix(t.BaseURL, "/")+"/chat/completions", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+t.APIKey)
+
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("translation API failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var completion struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(body, &completion); err != nil {
+		return nil, fmt.Errorf("failed to parse translation response: %v", err)
+	}
+	if len(completion.Choices) == 0 {
+		return nil, fmt.Errorf("translation response has no choices")
+	}
+
+	var translated []string
+	if err := json.Unmarshal([]byte(stripCodeFence(completion.Choices[0].Message.Content)), &translated); err != nil {
+		return nil, fmt.Errorf("translation is not a JSON array: %v", err)
+	}
+	if len(translated) != len(texts) {
+		return nil, fmt.Errorf("got %d translations for %d lines", len(translated), len(texts))
+	}
+	return translated, nil
+}
+
+// stripCodeFence removes the Markdown code fence that models often wrap JSON
+// answers in.
+func stripCodeFence(s string) string {
+	s = strings.TrimSpace(s)
+	if rest, ok := strings.CutPrefix(s, "```"); ok {
+		if i := strings.IndexByte(rest, '\n'); i >= 0 {
+			rest = rest[i+1:]
+		}
+		s = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(rest), "```"))
+	}
+	return s
+}
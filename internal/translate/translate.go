@@ -0,0 +1,39 @@
+package translate
+
+import (
+	"fmt"
+
+	"github.com/LeonRhapsody/pptTovideo/internal/config"
+)
+
+// Translator translates text between languages.
+type Translator interface {
+	// Translate returns the translations of texts in the same order.
+	// Languages are codes or names such as "zh" or "English"; an empty
+	// source language is detected.
+	Translate(texts []string, source, target string) ([]string, error)
+}
+
+type Backend string
+
+const (
+	BackendOpenAI     Backend = "openai"     // Any OpenAI-compatible chat completions API
+	BackendDictionary Backend = "dictionary" // A fixed phrase list, for offline use and tests
+)
+
+// Backends lists the supported backends.
+var Backends = []Backend{BackendOpenAI, BackendDictionary}
+
+// NewTranslator returns the translator of a backend.
+func NewTranslator(backend Backend, cfg *config.Config) (Translator, error) {
+	switch backend {
+	case BackendOpenAI:
+		return NewOpenAITranslator(cfg), nil
+	case BackendDictionary:
+		if cfg.TranslationDictionary == "" {
+			return nil, fmt.Errorf("no translation dictionary configured")
+		}
+		return LoadDictionary(cfg.TranslationDictionary)
+	}
+	return nil, fmt.Errorf("unknown translation backend %q", backend)
+}
@@ -0,0 +1,207 @@
+package tts
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/LeonRhapsody/pptTovideo/internal/audio"
+)
+
+// Delimiters used when splitting long text, from strongest to weakest.
+const (
+	sentenceDelimiters = "。！？!?\n"
+	clauseDelimiters   = "，、；：,;:"
+)
+
+// chunkedProvider splits text that exceeds the wrapped provider's input limit,
+// synthesizes each chunk and concatenates the audio into one file.
+type chunkedProvider struct {
+	LimitedProvider
+}
+
+func (c *chunkedProvider) Synthesize(text string, outputPath string, voiceName string, opts Options) error {
+	chunks := SplitText(text, c.MaxInput())
+	if len(chunks) <= 1 {
+		return c.LimitedProvider.Synthesize(text, outputPath, voiceName, opts)
+	}
+	_, err := c.synthesizeChunks(text, chunks, outputPath, voiceName, opts, false)
+	return err
+}
+
+// SynthesizeTimed collects the boundaries of each chunk and shifts them by
+// the duration of the chunks before it, so they are relative to the stitched
+// audio and to the original text.
+func (c *chunkedProvider) SynthesizeTimed(text string, outputPath string, voiceName string, opts Options) (*Result, error) {
+	chunks := SplitText(text, c.MaxInput())
+	if len(chunks) <= 1 {
+		return SynthesizeWithTimings(c.LimitedProvider, text, outputPath, voiceName, opts)
+	}
+	return c.synthesizeChunks(text, chunks, outputPath, voiceName, opts, true)
+}
+
+func (c *chunkedProvider) synthesizeChunks(text string, chunks []string, outputPath string, voiceName string, opts Options, timed bool) (*Result, error) {
+	tmpDir, err := os.MkdirTemp(filepath.Dir(outputPath), "chunks-*")
+	if err != nil {
+		return nil, fmt.Errorf("create chunk dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	var parts []string
+	merged := &Result{}
+	cursor := 0 // Byte position in text just past the previous chunk
+	for i, chunk := range chunks {
+		part := filepath.Join(tmpDir, fmt.Sprintf("chunk_%d.mp3", i))
+		if !timed {
+			if err := c.LimitedProvider.Synthesize(chunk, part, voiceName, opts); err != nil {
+				return nil, fmt.Errorf("chunk %d/%d: %w", i+1, len(chunks), err)
+			}
+		} else {
+			res, err := SynthesizeWithTimings(c.LimitedProvider, chunk, part, voiceName, opts)
+			if err != nil {
+				return nil, fmt.Errorf("chunk %d/%d: %w", i+1, len(chunks), err)
+			}
+			start := cursor
+			if idx := strings.Index(text[cursor:], chunk); idx >= 0 {
+				start += idx
+			}
+			cursor = start + len(chunk)
+			runeOffset := utf8.RuneCountInString(text[:start])
+			for _, b := range res.Boundaries {
+				b.Offset += runeOffset
+				b.Start += merged.Duration
+				b.End += merged.Duration
+				merged.Boundaries = append(merged.Boundaries, b)
+			}
+			merged.Duration += res.Duration
+			merged.Estimated = merged.Estimated || res.Estimated
+		}
+		parts = append(parts, part)
+	}
+
+	if err := audio.Concat(parts, outputPath); err != nil {
+		return nil, fmt.Errorf("stitching %d chunks: %w", len(chunks), err)
+	}
+	return merged, nil
+}
+
+// SplitText breaks text into chunks that each fit within limit.
+// It prefers sentence boundaries, then clause boundaries, and only cuts
+// inside a clause (at whitespace if possible) when a clause alone is too long.
+func SplitText(text string, limit InputLimit) []string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	if limit.Max <= 0 || limit.size(text) <= limit.Max {
+		return []string{text}
+	}
+
+	var chunks []string
+	var current strings.Builder
+
+	flush := func() {
+		if s := strings.TrimSpace(current.String()); s != "" {
+			chunks = append(chunks, s)
+		}
+		current.Reset()
+	}
+
+	for _, sentence := range splitKeepingDelimiters(text, isSentenceEnd) {
+		for _, piece := range limit.fit(sentence) {
+			if limit.size(current.String())+limit.size(piece) > limit.Max {
+				flush()
+			}
+			current.WriteString(piece)
+		}
+	}
+	flush()
+
+	return chunks
+}
+
+// fit breaks a single sentence into pieces no larger than the limit.
+func (l InputLimit) fit(sentence string) []string {
+	if l.size(sentence) <= l.Max {
+		return []string{sentence}
+	}
+
+	var pieces []string
+	for _, clause := range splitKeepingDelimiters(sentence, isClauseEnd) {
+		for l.size(clause) > l.Max {
+			cut := l.cutIndex(clause)
+			pieces = append(pieces, clause[:cut])
+			clause = clause[cut:]
+		}
+		if clause != "" {
+			pieces = append(pieces, clause)
+		}
+	}
+	return pieces
+}
+
+// cutIndex returns the byte offset at which to hard-split s so the head fits
+// within the limit, preferring the last whitespace before that point.
+func (l InputLimit) cutIndex(s string) int {
+	cut, lastSpace := 0, 0
+	for i, r := range s {
+		if l.size(s[:i+utf8.RuneLen(r)]) > l.Max {
+			break
+		}
+		cut = i + utf8.RuneLen(r)
+		if unicode.IsSpace(r) {
+			lastSpace = cut
+		}
+	}
+	if lastSpace > 0 {
+		return lastSpace
+	}
+	if cut == 0 {
+		// Limit smaller than a single rune; make progress anyway.
+		_, n := utf8.DecodeRuneInString(s)
+		return n
+	}
+	return cut
+}
+
+func (l InputLimit) size(s string) int {
+	if l.Unit == LimitBytes {
+		return len(s)
+	}
+	return utf8.RuneCountInString(s)
+}
+
+// splitKeepingDelimiters splits s after every rune for which isDelim reports
+// true, keeping the delimiter attached to the preceding piece.
+func splitKeepingDelimiters(s string, isDelim func(r rune, rest string) bool) []string {
+	var parts []string
+	start := 0
+	for i, r := range s {
+		end := i + utf8.RuneLen(r)
+		if isDelim(r, s[end:]) {
+			parts = append(parts, s[start:end])
+			start = end
+		}
+	}
+	if start < len(s) {
+		parts = append(parts, s[start:])
+	}
+	return parts
+}
+
+// isSentenceEnd treats '.' as a sentence end only when followed by whitespace,
+// so decimals and abbreviations such as "3.14" or "v1.2" stay intact.
+func isSentenceEnd(r rune, rest string) bool {
+	if r == '.' {
+		next, _ := utf8.DecodeRuneInString(rest)
+		return rest == "" || unicode.IsSpace(next)
+	}
+	return strings.ContainsRune(sentenceDelimiters, r)
+}
+
+func isClauseEnd(r rune, rest string) bool {
+	return strings.ContainsRune(clauseDelimiters, r)
+}
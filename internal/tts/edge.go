@@ -2,12 +2,41 @@ package tts
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"time"
 )
 
+// edgeScript streams synthesis through the edge_tts Python API so that the
+// word boundary events can be captured alongside the audio. Boundaries are
+// printed to stdout as JSON, with offsets and durations in 100ns ticks.
+const edgeScript = `
+import asyncio, json, sys
+import edge_tts
+
+async def main():
+    text, voice, rate, volume, pitch, out = sys.argv[1:7]
+    kwargs = dict(rate=rate, volume=volume, pitch=pitch)
+    try:
+        communicate = edge_tts.Communicate(text, voice, boundary="WordBoundary", **kwargs)
+    except TypeError:
+        # Older edge_tts releases always emit word boundaries.
+        communicate = edge_tts.Communicate(text, voice, **kwargs)
+    marks = []
+    with open(out, "wb") as f:
+        async for chunk in communicate.stream():
+            if chunk["type"] == "audio":
+                f.write(chunk["data"])
+            elif chunk["type"] in ("WordBoundary", "SentenceBoundary"):
+                marks.append({"offset": chunk["offset"], "duration": chunk["duration"], "text": chunk["text"]})
+    json.dump(marks, sys.stdout, ensure_ascii=False)
+
+asyncio.run(main())
+`
+
 type EdgeProvider struct {
 	// Default voice if none provided
 	DefaultVoice string
@@ -20,6 +49,13 @@ func NewEdgeProvider() *EdgeProvider {
 }
 
 func (e *EdgeProvider) Synthesize(text string, outputPath string, voiceName string, opts Options) error {
+	_, err := e.SynthesizeTimed(text, outputPath, voiceName, opts)
+	return err
+}
+
+// SynthesizeTimed synthesizes text and returns the WordBoundary events that
+// Edge reports for it.
+func (e *EdgeProvider) SynthesizeTimed(text string, outputPath string, voiceName string, opts Options) (*Result, error) {
 	if voiceName == "" {
 		voiceName = e.DefaultVoice
 	}
@@ -46,38 +82,62 @@ func (e *EdgeProvider) Synthesize(text string, outputPath string, voiceName stri
 		// Use a context with timeout to prevent hangs
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 
-		// Use python3.9 -m edge_tts since we installed it there
-		cmd := exec.CommandContext(ctx, "python3.9", "-m", "edge_tts",
-			"--text", text,
-			"--write-media", outputPath,
-			"--voice", voiceName,
-			"--rate="+opts.Rate,
-			"--volume="+opts.Volume,
-			"--pitch="+opts.Pitch,
-		)
-
-		output, err := cmd.CombinedOutput()
+		// Use python3.9 since edge_tts is installed there
+		cmd := exec.CommandContext(ctx, "python3.9", "-c", edgeScript,
+			text, voiceName, opts.Rate, opts.Volume, opts.Pitch, outputPath)
+
+		output, err := cmd.Output()
 		cancel() // Cancel context after command finishes
 
 		if err == nil {
 			// Verify file exists and is not empty
 			info, err := os.Stat(outputPath)
 			if err == nil && info.Size() > 0 {
-				return nil // Success
+				return parseEdgeBoundaries(text, output), nil // Success
 			}
 			if err != nil {
 				lastErr = fmt.Errorf("failed to stat output file: %w", err)
 			} else {
-				lastErr = fmt.Errorf("edge-tts generated empty file, output: %s", string(output))
+				lastErr = fmt.Errorf("edge-tts generated empty file")
 			}
 		} else {
+			var exitErr *exec.ExitError
 			if ctx.Err() == context.DeadlineExceeded {
 				lastErr = fmt.Errorf("edge-tts synthesis timed out after 30s")
+			} else if errors.As(err, &exitErr) {
+				lastErr = fmt.Errorf("edge-tts failed: %w, output: %s", err, string(exitErr.Stderr))
 			} else {
-				lastErr = fmt.Errorf("edge-tts cli failed: %w, output: %s", err, string(output))
+				lastErr = fmt.Errorf("edge-tts failed: %w", err)
 			}
 		}
 	}
 
-	return fmt.Errorf("synthesis failed after %d attempts: %v", maxRetries, lastErr)
+	return nil, fmt.Errorf("synthesis failed after %d attempts: %v", maxRetries, lastErr)
+}
+
+// parseEdgeBoundaries converts the JSON printed by edgeScript to boundaries.
+// Malformed output yields an empty result so the caller falls back to
+// estimated timings rather than failing a synthesis that produced audio.
+func parseEdgeBoundaries(text string, output []byte) *Result {
+	var marks []struct {
+		Offset   int64  `json:"offset"`
+		Duration int64  `json:"duration"`
+		Text     string `json:"text"`
+	}
+	if err := json.Unmarshal(output, &marks); err != nil {
+		return &Result{}
+	}
+
+	const ticksPerSecond = 1e7
+	res := &Result{}
+	for _, m := range marks {
+		b := Boundary{
+			Text:  m.Text,
+			Start: float64(m.Offset) / ticksPerSecond,
+			End:   float64(m.Offset+m.Duration) / ticksPerSecond,
+		}
+		res.Boundaries = append(res.Boundaries, b)
+	}
+	locateBoundaries(text, res.Boundaries)
+	return res
 }
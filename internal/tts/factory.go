@@ -4,6 +4,7 @@ import (
 	"errors"
 
 	"github.com/LeonRhapsody/pptTovideo/internal/config"
+	"github.com/LeonRhapsody/pptTovideo/internal/voices"
 )
 
 type EngineType string
@@ -18,8 +19,39 @@ const (
 	EngineFish   EngineType = "fishspeech"
 )
 
+// BuiltinEngines lists the engines implemented in Go. They take precedence
+// over custom engines registered under the same name.
+var BuiltinEngines = []EngineType{
+	EngineEdge, EngineSystem, EngineXunfei, EngineVolc, EngineGoogle, EngineOpenAI, EngineFish,
+}
+
 // NewTTSProvider returns a TTSProvider based on the engine type.
-func NewTTSProvider(engine EngineType, cfg *config.Config) (TTSProvider, error) {
+// Providers with an input limit are wrapped so that longer text is split at
+// sentence or clause boundaries and the resulting audio stitched together.
+// refs holds the uploaded voices that engines able to clone them read.
+func NewTTSProvider(engine EngineType, cfg *config.Config, refs *voices.Store) (TTSProvider, error) {
+	return NewMeteredTTSProvider(engine, cfg, refs, nil)
+}
+
+// NewMeteredTTSProvider is NewTTSProvider with every request to the engine
+// reported to meter, which may be nil.
+func NewMeteredTTSProvider(engine EngineType, cfg *config.Config, refs *voices.Store, meter Meter) (TTSProvider, error) {
+	p, err := newProvider(engine, cfg, refs)
+	if err != nil {
+		return nil, err
+	}
+	lp, limited := p.(LimitedProvider)
+	if meter != nil {
+		lp = &meteredProvider{provider: p, engine: engine, meter: meter}
+		p = lp
+	}
+	if limited {
+		return &chunkedProvider{LimitedProvider: lp}, nil
+	}
+	return p, nil
+}
+
+func newProvider(engine EngineType, cfg *config.Config, refs *voices.Store) (TTSProvider, error) {
 	switch engine {
 	case EngineXunfei:
 		return NewXunfeiProvider(cfg), nil
@@ -34,8 +66,11 @@ func NewTTSProvider(engine EngineType, cfg *config.Config) (TTSProvider, error)
 	case EngineOpenAI:
 		return NewOpenAIProvider(cfg), nil
 	case EngineFish:
-		return NewFishSpeechProvider(cfg), nil
+		return NewFishSpeechProvider(cfg, refs), nil
 	default:
+		if custom, ok := cfg.CustomEngine(string(engine)); ok {
+			return NewTemplateProvider(custom)
+		}
 		return nil, errors.New("unsupported TTS engine")
 	}
 }
@@ -0,0 +1,86 @@
+package tts
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/LeonRhapsody/pptTovideo/internal/config"
+	"github.com/LeonRhapsody/pptTovideo/internal/voices"
+)
+
+// Voice identifies a voice of a particular engine.
+type Voice struct {
+	Engine EngineType
+	Name   string
+}
+
+// FallbackChain synthesizes with the first voice and moves on to the next one
+// whenever a provider fails, after that provider has exhausted its own retries.
+type FallbackChain struct {
+	Voices    []Voice
+	providers map[EngineType]TTSProvider
+}
+
+// NewFallbackChain creates providers for every engine in voices, which read
+// uploaded voices from refs and report their requests to meter if it is not
+// nil. Engines that are not supported
+// are rejected up front rather than mid-render.
+func NewFallbackChain(voices []Voice, cfg *config.Config, refs *voices.Store, meter Meter) (*FallbackChain, error) {
+	if len(voices) == 0 {
+		return nil, fmt.Errorf("no TTS voices configured")
+	}
+
+	chain := &FallbackChain{
+		Voices:    voices,
+		providers: make(map[EngineType]TTSProvider),
+	}
+	for _, v := range voices {
+		if _, ok := chain.providers[v.Engine]; ok {
+			continue
+		}
+		p, err := NewMeteredTTSProvider(v.Engine, cfg, refs, meter)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", v.Engine, err)
+		}
+		chain.providers[v.Engine] = p
+	}
+	return chain, nil
+}
+
+// Synthesis describes the outcome of a FallbackChain synthesis.
+type Synthesis struct {
+	Voice    Voice   // Voice that produced the audio
+	Failures []error // Errors of the voices tried before it
+	Result   *Result // Word or character timings of the audio
+}
+
+// Synthesize tries each voice of the chain in order until one succeeds.
+func (c *FallbackChain) Synthesize(text string, outputPath string, opts Options) (*Synthesis, error) {
+	var failures []error
+	for _, v := range c.Voices {
+		res, err := SynthesizeWithTimings(c.providers[v.Engine], text, outputPath, v.Name, opts)
+		if err == nil {
+			return &Synthesis{Voice: v, Failures: failures, Result: res}, nil
+		}
+		failures = append(failures, fmt.Errorf("%s/%s: %w", v.Engine, v.Name, err))
+	}
+
+	msgs := make([]string, len(failures))
+	for i, err := range failures {
+		msgs[i] = err.Error()
+	}
+	return nil, fmt.Errorf("all %d TTS voices failed: %s", len(c.Voices), strings.Join(msgs, "; "))
+}
+
+// FallbackVoices converts configured fallbacks to voices, skipping entries
+// without an engine.
+func FallbackVoices(fallbacks []config.TTSFallback) []Voice {
+	var voices []Voice
+	for _, f := range fallbacks {
+		if f.Engine == "" {
+			continue
+		}
+		voices = append(voices, Voice{Engine: EngineType(f.Engine), Name: f.Voice})
+	}
+	return voices
+}
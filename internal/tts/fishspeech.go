@@ -2,6 +2,7 @@ package tts
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,14 +10,18 @@ import (
 	"os"
 
 	"github.com/LeonRhapsody/pptTovideo/internal/config"
+	"github.com/LeonRhapsody/pptTovideo/internal/voices"
 )
 
 type FishSpeechProvider struct {
 	Config *config.Config
+	Voices *voices.Store
 }
 
-func NewFishSpeechProvider(cfg *config.Config) *FishSpeechProvider {
-	return &FishSpeechProvider{Config: cfg}
+// NewFishSpeechProvider returns a provider that clones the uploaded voices of
+// refs, which is shared with the voice API so that access stays serialized.
+func NewFishSpeechProvider(cfg *config.Config, refs *voices.Store) *FishSpeechProvider {
+	return &FishSpeechProvider{Config: cfg, Voices: refs}
 }
 
 func (p *FishSpeechProvider) Synthesize(text string, outputPath string, voiceName string, opts Options) error {
@@ -36,9 +41,30 @@ func (p *FishSpeechProvider) Synthesize(text string, outputPath string, voiceNam
 	}
 
 	reqBody := map[string]interface{}{
-		"text":         text,
-		"reference_id": voiceName,
-		"format":       "mp3",
+		"text":   text,
+		"format": "mp3",
+	}
+
+	if workspace, id, ok := voices.ParseVoiceName(voiceName); ok {
+		// Uploaded voice: send the sample and its transcript inline so that
+		// the server clones it without a model stored on its side.
+		if p.Voices == nil {
+			return fmt.Errorf("uploaded voices are not available")
+		}
+		ref, err := p.Voices.Get(workspace, id)
+		if err != nil {
+			return err
+		}
+		sample, err := os.ReadFile(p.Voices.AudioPath(ref))
+		if err != nil {
+			return fmt.Errorf("reading reference audio: %w", err)
+		}
+		reqBody["references"] = []map[string]interface{}{{
+			"audio": base64.StdEncoding.EncodeToString(sample),
+			"text":  ref.Transcript,
+		}}
+	} else {
+		reqBody["reference_id"] = voiceName
 	}
 
 	jsonData, err := json.Marshal(reqBody)
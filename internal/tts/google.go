@@ -5,10 +5,13 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"html"
 	"io"
 	"net/http"
 	"os"
+	"strings"
 
+	"github.com/LeonRhapsody/pptTovideo/internal/audio"
 	"github.com/LeonRhapsody/pptTovideo/internal/config"
 )
 
@@ -20,13 +23,110 @@ func NewGoogleProvider(cfg *config.Config) *GoogleProvider {
 	return &GoogleProvider{Config: cfg}
 }
 
+// MaxInput reflects the 5000 byte limit on the synthesis input.
+func (p *GoogleProvider) MaxInput() InputLimit {
+	return InputLimit{Max: 5000, Unit: LimitBytes}
+}
+
 func (p *GoogleProvider) Synthesize(text string, outputPath string, voiceName string, opts Options) error {
+	_, err := p.synthesize(map[string]interface{}{"text": text}, false, outputPath, voiceName)
+	return err
+}
+
+// SynthesizeTimed wraps the text in SSML with a <mark> before each word or
+// character and asks the API for the time at which each mark is reached.
+// Marks count towards the input limit, so on long text only every n-th token
+// is marked and the characters in between are spread proportionally.
+func (p *GoogleProvider) SynthesizeTimed(text string, outputPath string, voiceName string, opts Options) (*Result, error) {
+	tokens := tokenize(text)
+	ssml, step := "", 1
+	for ; step <= len(tokens); step *= 2 {
+		if ssml = googleSSML(text, tokens, step); len(ssml) <= p.MaxInput().Max {
+			break
+		}
+	}
+	if step > len(tokens) {
+		// Not even sparse marks fit; synthesize plain text and estimate.
+		return &Result{}, p.Synthesize(text, outputPath, voiceName, opts)
+	}
+
+	timepoints, err := p.synthesize(map[string]interface{}{"ssml": ssml}, true, outputPath, voiceName)
+	if err != nil {
+		return nil, err
+	}
+	dur, err := audio.Duration(outputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each mark's time is the start of its group of tokens; spread the text
+	// of the group until the next mark, or the end of the audio.
+	var boundaries []Boundary
+	runes := []rune(text)
+	for i, tp := range timepoints {
+		var idx int
+		if _, err := fmt.Sscanf(tp.MarkName, "t%d", &idx); err != nil || idx >= len(tokens) {
+			continue
+		}
+		endOffset, endTime := len(runes), dur
+		if i+1 < len(timepoints) {
+			var next int
+			if _, err := fmt.Sscanf(timepoints[i+1].MarkName, "t%d", &next); err == nil && next < len(tokens) {
+				endOffset, endTime = tokens[next].Offset, timepoints[i+1].TimeSeconds
+			}
+		}
+		for _, b := range EstimateBoundaries(string(runes[tokens[idx].Offset:endOffset]), endTime-tp.TimeSeconds) {
+			b.Offset += tokens[idx].Offset
+			b.Start += tp.TimeSeconds
+			b.End += tp.TimeSeconds
+			boundaries = append(boundaries, b)
+		}
+	}
+	return &Result{Boundaries: boundaries, Duration: dur}, nil
+}
+
+// googleSSML builds SSML from text with a mark named "t<index>" before every
+// step-th spoken token.
+func googleSSML(text string, tokens []token, step int) string {
+	marks := make(map[int]int) // Rune offset -> token index
+	spoken := 0
+	for i, t := range tokens {
+		if t.Spoken {
+			if spoken%step == 0 {
+				marks[t.Offset] = i
+			}
+			spoken++
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("<speak>")
+	for i, r := range []rune(text) {
+		if idx, ok := marks[i]; ok {
+			fmt.Fprintf(&sb, `<mark name="t%d"/>`, idx)
+		}
+		sb.WriteString(html.EscapeString(string(r)))
+	}
+	sb.WriteString("</speak>")
+	return sb.String()
+}
+
+type googleTimepoint struct {
+	MarkName    string  `json:"markName"`
+	TimeSeconds float64 `json:"timeSeconds"`
+}
+
+func (p *GoogleProvider) synthesize(input map[string]interface{}, marks bool, outputPath string, voiceName string) ([]googleTimepoint, error) {
 	apiKey := p.Config.GoogleAPIKey
 	if apiKey == "" {
-		return fmt.Errorf("Google Cloud API Key not configured")
+		return nil, fmt.Errorf("Google Cloud API Key not configured")
 	}
 
+	// Timepoints are only available from the v1beta1 API.
 	url := "https://texttospeech.googleapis.com/v1/text:synthesize?key=" + apiKey
+	if marks {
+		url = "https://texttospeech.googleapis.com/v1beta1/text:synthesize?key=" + apiKey
+	}
 
 	if voiceName == "" {
 		voiceName = "cmn-CN-Wavenet-A" // Default Chinese
@@ -39,9 +139,7 @@ func (p *GoogleProvider) Synthesize(text string, outputPath string, voiceName st
 	}
 
 	reqBody := map[string]interface{}{
-		"input": map[string]interface{}{
-			"text": text,
-		},
+		"input": input,
 		"voice": map[string]interface{}{
 			"languageCode": langCode,
 			"name":         voiceName,
@@ -53,6 +151,9 @@ func (p *GoogleProvider) Synthesize(text string, outputPath string, voiceName st
 			"volumeGainDb":  0,
 		},
 	}
+	if marks {
+		reqBody["enableTimePointing"] = []string{"SSML_MARK"}
+	}
 
 	// Add param mapping logic here if needed
 
@@ -60,7 +161,7 @@ func (p *GoogleProvider) Synthesize(text string, outputPath string, voiceName st
 
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -68,27 +169,28 @@ func (p *GoogleProvider) Synthesize(text string, outputPath string, voiceName st
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("Google API failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("Google API failed with status %d: %s", resp.StatusCode, string(body))
 	}
 
 	// Google returns JSON with "audioContent": base64 string
 	var result struct {
-		AudioContent string `json:"audioContent"`
+		AudioContent string            `json:"audioContent"`
+		Timepoints   []googleTimepoint `json:"timepoints"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return err
+		return nil, err
 	}
 
 	decoded, err := base64.StdEncoding.DecodeString(result.AudioContent)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return os.WriteFile(outputPath, decoded, 0644)
+	return result.Timepoints, os.WriteFile(outputPath, decoded, 0644)
 }
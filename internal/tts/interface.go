@@ -12,3 +12,49 @@ type TTSProvider interface {
 	// Synthesize converts text to speech and saves it to the specified output path.
 	Synthesize(text string, outputPath string, voiceName string, opts Options) error
 }
+
+// LimitUnit is the unit an engine measures its input size in.
+type LimitUnit int
+
+const (
+	LimitRunes LimitUnit = iota // Characters
+	LimitBytes                  // UTF-8 encoded bytes
+)
+
+// InputLimit is the largest text an engine accepts in a single request.
+type InputLimit struct {
+	Max  int
+	Unit LimitUnit
+}
+
+// LimitedProvider is implemented by providers whose API caps the size of a
+// single request. NewTTSProvider wraps them so that longer text is split and
+// stitched transparently.
+type LimitedProvider interface {
+	TTSProvider
+	MaxInput() InputLimit
+}
+
+// Boundary marks when a word or character of the input text is spoken.
+type Boundary struct {
+	Text   string  `json:"text"`
+	Offset int     `json:"offset"` // Rune offset of Text within the input
+	Start  float64 `json:"start"`  // Seconds from the start of the audio
+	End    float64 `json:"end"`
+}
+
+// Result carries metadata produced alongside the synthesized audio.
+type Result struct {
+	Boundaries []Boundary `json:"boundaries"`
+	Duration   float64    `json:"duration"`
+	// Estimated is set when the boundaries were derived from the audio
+	// duration rather than reported by the engine.
+	Estimated bool `json:"estimated"`
+}
+
+// TimedProvider is implemented by providers whose engine reports when each
+// word or character is spoken.
+type TimedProvider interface {
+	TTSProvider
+	SynthesizeTimed(text string, outputPath string, voiceName string, opts Options) (*Result, error)
+}
@@ -0,0 +1,46 @@
+package tts
+
+// Meter is called with the text of every request made to an engine, for
+// usage accounting. Long text that a provider splits into several requests is
+// reported once per request. Failed requests are reported too, with failed
+// set, as an engine may bill a request that fails after it was accepted, such
+// as one that times out.
+type Meter func(engine EngineType, text string, failed bool)
+
+// meteredProvider reports the requests of the provider it wraps to a Meter.
+// It sits below the chunking wrapper, so that every chunk is counted, and
+// forwards the input limit and timings of the provider it wraps.
+type meteredProvider struct {
+	provider TTSProvider
+	engine   EngineType
+	meter    Meter
+}
+
+func (p *meteredProvider) Synthesize(text string, outputPath string, voiceName string, opts Options) error {
+	err := p.provider.Synthesize(text, outputPath, voiceName, opts)
+	p.meter(p.engine, text, err != nil)
+	return err
+}
+
+// SynthesizeTimed asks the wrapped provider for timings if it reports them.
+// Otherwise the result is left empty for SynthesizeWithTimings to estimate.
+func (p *meteredProvider) SynthesizeTimed(text string, outputPath string, voiceName string, opts Options) (*Result, error) {
+	tp, ok := p.provider.(TimedProvider)
+	if !ok {
+		return &Result{}, p.Synthesize(text, outputPath, voiceName, opts)
+	}
+	res, err := tp.SynthesizeTimed(text, outputPath, voiceName, opts)
+	p.meter(p.engine, text, err != nil)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// MaxInput is the limit of the wrapped provider, or no limit.
+func (p *meteredProvider) MaxInput() InputLimit {
+	if lp, ok := p.provider.(LimitedProvider); ok {
+		return lp.MaxInput()
+	}
+	return InputLimit{}
+}
@@ -19,6 +19,11 @@ func NewOpenAIProvider(cfg *config.Config) *OpenAIProvider {
 	return &OpenAIProvider{Config: cfg}
 }
 
+// MaxInput reflects the 4096 character limit of the speech endpoint.
+func (p *OpenAIProvider) MaxInput() InputLimit {
+	return InputLimit{Max: 4096, Unit: LimitRunes}
+}
+
 func (p *OpenAIProvider) Synthesize(text string, outputPath string, voiceName string, opts Options) error {
 	apiKey := p.Config.OpenAIAPIKey
 	if apiKey == "" {
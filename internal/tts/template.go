@@ -0,0 +1,271 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/LeonRhapsody/pptTovideo/internal/config"
+)
+
+// TemplateProvider synthesizes speech with a custom engine defined in config,
+// either by sending a templated HTTP request or by running a templated command.
+type TemplateProvider struct {
+	Engine config.CustomEngine
+}
+
+// limitedTemplateProvider is a TemplateProvider with a configured input limit,
+// so that the factory wraps it for chunking.
+type limitedTemplateProvider struct {
+	*TemplateProvider
+}
+
+func (p *limitedTemplateProvider) MaxInput() InputLimit {
+	return InputLimit{Max: p.Engine.MaxInput, Unit: LimitRunes}
+}
+
+// NewTemplateProvider validates the engine definition and returns its provider.
+func NewTemplateProvider(engine config.CustomEngine) (TTSProvider, error) {
+	if (engine.HTTP == nil) == (len(engine.Command) == 0) {
+		return nil, fmt.Errorf("custom engine %q must define exactly one of http or command", engine.Name)
+	}
+	if engine.HTTP != nil && engine.HTTP.URL == "" {
+		return nil, fmt.Errorf("custom engine %q has no url", engine.Name)
+	}
+	if engine.AudioFormat == "pcm" && engine.SampleRate <= 0 {
+		return nil, fmt.Errorf("custom engine %q needs sample_rate for pcm audio", engine.Name)
+	}
+
+	p := &TemplateProvider{Engine: engine}
+	if engine.MaxInput > 0 {
+		return &limitedTemplateProvider{p}, nil
+	}
+	return p, nil
+}
+
+// templateData is what request and command templates are executed with.
+type templateData struct {
+	Text   string
+	Voice  string
+	Rate   string
+	Volume string
+	Pitch  string
+	Speed  float64
+
+	// Only set for command templates.
+	TextFile string
+	Output   string
+}
+
+func (p *TemplateProvider) Synthesize(text string, outputPath string, voiceName string, opts Options) error {
+	if voiceName == "" && len(p.Engine.Voices) > 0 {
+		voiceName = p.Engine.Voices[0]
+	}
+	data := templateData{
+		Text:   text,
+		Voice:  voiceName,
+		Rate:   opts.Rate,
+		Volume: opts.Volume,
+		Pitch:  opts.Pitch,
+		Speed:  1 + parsePercent(opts.Rate)/100,
+	}
+
+	timeout := time.Duration(p.Engine.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	rawPath := outputPath
+	if p.Engine.AudioFormat == "pcm" {
+		rawPath = outputPath + ".pcm"
+		defer os.Remove(rawPath)
+	}
+
+	var err error
+	if p.Engine.HTTP != nil {
+		err = p.request(ctx, data, rawPath)
+	} else {
+		err = p.command(ctx, data, rawPath)
+	}
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("%s tts timed out", p.Engine.Name)
+		}
+		return err
+	}
+
+	if p.Engine.AudioFormat == "pcm" {
+		cmd := exec.CommandContext(ctx, "ffmpeg", "-f", "s16le", "-ar", strconv.Itoa(p.Engine.SampleRate), "-ac", "1", "-i", rawPath, "-y", outputPath)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("ffmpeg conversion failed: %w, output: %s", err, string(out))
+		}
+	}
+	return nil
+}
+
+func (p *TemplateProvider) request(ctx context.Context, data templateData, outputPath string) error {
+	h := p.Engine.HTTP
+
+	reqURL, err := execTemplate("url", h.URL, data, url.QueryEscape)
+	if err != nil {
+		return err
+	}
+	body, err := execTemplate("body", h.Body, data, jsonEscape)
+	if err != nil {
+		return err
+	}
+
+	method := h.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range h.Headers {
+		v, err := execTemplate("header "+name, value, data, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set(name, v)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s API failed with status %d: %s", p.Engine.Name, resp.StatusCode, string(respBody))
+	}
+
+	if h.AudioField == "" {
+		outFile, err := os.Create(outputPath)
+		if err != nil {
+			return err
+		}
+		defer outFile.Close()
+		_, err = io.Copy(outFile, resp.Body)
+		return err
+	}
+
+	var payload interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return fmt.Errorf("decoding %s response: %w", p.Engine.Name, err)
+	}
+	for _, key := range strings.Split(h.AudioField, ".") {
+		obj, ok := payload.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s response has no field %q", p.Engine.Name, h.AudioField)
+		}
+		payload = obj[key]
+	}
+	encoded, ok := payload.(string)
+	if !ok {
+		return fmt.Errorf("%s response field %q is not a string", p.Engine.Name, h.AudioField)
+	}
+	audioData, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("decoding %s audio: %w", p.Engine.Name, err)
+	}
+	return os.WriteFile(outputPath, audioData, 0644)
+}
+
+func (p *TemplateProvider) command(ctx context.Context, data templateData, outputPath string) error {
+	textFile, err := os.CreateTemp("", "tts-text-*.txt")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(textFile.Name())
+	_, err = textFile.WriteString(data.Text)
+	textFile.Close()
+	if err != nil {
+		return err
+	}
+
+	// Start from an empty output so that a stale file is not mistaken for
+	// the command's result.
+	os.Remove(outputPath)
+	data.TextFile = textFile.Name()
+	data.Output = outputPath
+
+	args := make([]string, len(p.Engine.Command))
+	for i, arg := range p.Engine.Command {
+		if args[i], err = execTemplate("command", arg, data, nil); err != nil {
+			return err
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s tts failed: %w, output: %s", p.Engine.Name, err, stderr.String())
+	}
+
+	if info, err := os.Stat(outputPath); err == nil && info.Size() > 0 {
+		return nil
+	}
+	if stdout.Len() == 0 {
+		return fmt.Errorf("%s tts produced no audio", p.Engine.Name)
+	}
+	return os.WriteFile(outputPath, stdout.Bytes(), 0644)
+}
+
+// execTemplate executes text with data, passing every string field through
+// escape first when it is not nil.
+func execTemplate(name string, text string, data templateData, escape func(string) string) (string, error) {
+	if escape != nil {
+		data.Text = escape(data.Text)
+		data.Voice = escape(data.Voice)
+		data.Rate = escape(data.Rate)
+		data.Volume = escape(data.Volume)
+		data.Pitch = escape(data.Pitch)
+	}
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s template: %w", name, err)
+	}
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("executing %s template: %w", name, err)
+	}
+	return buf.String(), nil
+}
+
+// jsonEscape escapes s for use inside a JSON string literal.
+func jsonEscape(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b[1 : len(b)-1])
+}
+
+// parsePercent parses a relative prosody value such as "+20%" or "-10%".
+// Invalid or empty values yield 0.
+func parsePercent(s string) float64 {
+	s = strings.TrimPrefix(strings.TrimSuffix(strings.TrimSpace(s), "%"), "+")
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	return v
+}
@@ -0,0 +1,136 @@
+package tts
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/LeonRhapsody/pptTovideo/internal/audio"
+)
+
+// SynthesizeWithTimings synthesizes text and returns word or character
+// boundaries along with the audio duration. Engines that report timings are
+// asked for them; for all others the boundaries are estimated proportionally
+// over the audio duration.
+func SynthesizeWithTimings(p TTSProvider, text string, outputPath string, voiceName string, opts Options) (*Result, error) {
+	res := &Result{}
+	if tp, ok := p.(TimedProvider); ok {
+		var err error
+		if res, err = tp.SynthesizeTimed(text, outputPath, voiceName, opts); err != nil {
+			return nil, err
+		}
+	} else if err := p.Synthesize(text, outputPath, voiceName, opts); err != nil {
+		return nil, err
+	}
+
+	if res.Duration == 0 {
+		dur, err := audio.Duration(outputPath)
+		if err != nil {
+			return nil, err
+		}
+		res.Duration = dur
+	}
+	// Engines may return no marks at all, e.g. for very short input.
+	if len(res.Boundaries) == 0 {
+		res.Boundaries = EstimateBoundaries(text, res.Duration)
+		res.Estimated = true
+	}
+	return res, nil
+}
+
+// token is a unit of text for timing purposes: a CJK character, a run of
+// letters or digits, or a punctuation mark.
+type token struct {
+	Text   string
+	Offset int // Rune offset within the source text
+	Spoken bool
+	Weight float64
+}
+
+// tokenize splits text into tokens with a rough relative speaking time.
+// Punctuation is not spoken but carries weight for the pause it causes.
+func tokenize(text string) []token {
+	var tokens []token
+	runes := []rune(text)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case isCJK(r):
+			tokens = append(tokens, token{Text: string(r), Offset: i, Spoken: true, Weight: 1})
+			i++
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			j := i
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '\'') && !isCJK(runes[j]) {
+				j++
+			}
+			// A Latin word takes roughly as long as two to three CJK characters.
+			tokens = append(tokens, token{Text: string(runes[i:j]), Offset: i, Spoken: true, Weight: 0.5 + 0.3*float64(j-i)})
+			i = j
+		default:
+			weight := 0.0
+			if strings.ContainsRune(sentenceDelimiters+".", r) {
+				weight = 1.5
+			} else if strings.ContainsRune(clauseDelimiters, r) {
+				weight = 0.75
+			}
+			tokens = append(tokens, token{Text: string(r), Offset: i, Weight: weight})
+			i++
+		}
+	}
+	return tokens
+}
+
+// EstimateBoundaries spreads the spoken words and characters of text over
+// duration seconds in proportion to their estimated speaking time.
+func EstimateBoundaries(text string, duration float64) []Boundary {
+	tokens := tokenize(text)
+
+	total := 0.0
+	for _, t := range tokens {
+		total += t.Weight
+	}
+	if total == 0 {
+		return nil
+	}
+
+	var boundaries []Boundary
+	elapsed := 0.0
+	for _, t := range tokens {
+		start := elapsed / total * duration
+		elapsed += t.Weight
+		if t.Spoken {
+			boundaries = append(boundaries, Boundary{
+				Text:   t.Text,
+				Offset: t.Offset,
+				Start:  start,
+				End:    elapsed / total * duration,
+			})
+		}
+	}
+	return boundaries
+}
+
+// locateBoundaries fills in the rune offsets of boundaries whose text was
+// reported by an engine, searching forward through the input text.
+func locateBoundaries(text string, boundaries []Boundary) {
+	runes := []rune(text)
+	pos := 0
+	for i := range boundaries {
+		boundaries[i].Offset = pos
+		needle := []rune(strings.TrimSpace(boundaries[i].Text))
+		for j := pos; len(needle) > 0 && j+len(needle) <= len(runes); j++ {
+			if string(runes[j:j+len(needle)]) == string(needle) {
+				boundaries[i].Offset = j
+				pos = j + len(needle)
+				break
+			}
+		}
+	}
+}
+
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
@@ -8,9 +8,12 @@ import (
 	"fmt"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/LeonRhapsody/pptTovideo/internal/audio"
 	"github.com/LeonRhapsody/pptTovideo/internal/config"
 	"github.com/gorilla/websocket"
 )
@@ -23,9 +26,29 @@ func NewXunfeiProvider(cfg *config.Config) *XunfeiProvider {
 	return &XunfeiProvider{Config: cfg}
 }
 
+// MaxInput reflects the 8000 byte limit on the base64 encoded text frame.
+func (x *XunfeiProvider) MaxInput() InputLimit {
+	return InputLimit{Max: 5800, Unit: LimitBytes}
+}
+
 func (x *XunfeiProvider) Synthesize(text string, outputPath string, voiceName string, opts Options) error {
+	_, err := x.SynthesizeTimed(text, outputPath, voiceName, opts)
+	return err
+}
+
+// xunfeiProgress relates the synthesis progress reported in a frame ("ced",
+// a byte offset into the text) to the amount of audio received so far.
+type xunfeiProgress struct {
+	TextBytes  int
+	AudioBytes int
+}
+
+// SynthesizeTimed derives timings from the "ced" progress of each frame.
+// The progress only has frame granularity, so characters within each span
+// are spread proportionally between its start and end.
+func (x *XunfeiProvider) SynthesizeTimed(text string, outputPath string, voiceName string, opts Options) (*Result, error) {
 	if x.Config.XunfeiAppID == "" || x.Config.XunfeiAPIKey == "" || x.Config.XunfeiAPISecret == "" {
-		return fmt.Errorf("Xunfei credentials not configured")
+		return nil, fmt.Errorf("Xunfei credentials not configured")
 	}
 
 	if voiceName == "" {
@@ -40,7 +63,7 @@ func (x *XunfeiProvider) Synthesize(text string, outputPath string, voiceName st
 	urlStr := x.assembleAuthUrl(hostUrl, x.Config.XunfeiAPIKey, x.Config.XunfeiAPISecret)
 	conn, _, err := d.Dial(urlStr, nil)
 	if err != nil {
-		return fmt.Errorf("dialing xunfei: %v", err)
+		return nil, fmt.Errorf("dialing xunfei: %v", err)
 	}
 	defer conn.Close()
 
@@ -65,37 +88,45 @@ func (x *XunfeiProvider) Synthesize(text string, outputPath string, voiceName st
 	}
 
 	if err := conn.WriteJSON(frameData); err != nil {
-		return fmt.Errorf("sending data: %v", err)
+		return nil, fmt.Errorf("sending data: %v", err)
 	}
 
 	outFile, err := os.Create(outputPath)
 	if err != nil {
-		return fmt.Errorf("create file: %v", err)
+		return nil, fmt.Errorf("create file: %v", err)
 	}
 	defer outFile.Close()
 
+	var progress []xunfeiProgress
+	audioBytes := 0
+
 	for {
 		_, msg, err := conn.ReadMessage()
 		if err != nil {
-			return fmt.Errorf("read message: %v", err)
+			return nil, fmt.Errorf("read message: %v", err)
 		}
 
 		var resp map[string]interface{}
 		if err := json.Unmarshal(msg, &resp); err != nil {
-			return err
+			return nil, err
 		}
 
 		if code, ok := resp["code"].(float64); ok && code != 0 {
-			return fmt.Errorf("xunfei api error code: %v, message: %v", code, resp["message"])
+			return nil, fmt.Errorf("xunfei api error code: %v, message: %v", code, resp["message"])
 		}
 
 		if data, ok := resp["data"].(map[string]interface{}); ok {
-			if audio, ok := data["audio"].(string); ok {
-				decoded, err := base64.StdEncoding.DecodeString(audio)
+			if chunk, ok := data["audio"].(string); ok {
+				decoded, err := base64.StdEncoding.DecodeString(chunk)
 				if err != nil {
-					return err
+					return nil, err
 				}
 				outFile.Write(decoded)
+				audioBytes += len(decoded)
+			}
+
+			if ced, err := strconv.Atoi(fmt.Sprint(data["ced"])); err == nil {
+				progress = append(progress, xunfeiProgress{TextBytes: ced, AudioBytes: audioBytes})
 			}
 
 			if status, ok := data["status"].(float64); ok && status == 2 {
@@ -104,8 +135,52 @@ func (x *XunfeiProvider) Synthesize(text string, outputPath string, voiceName st
 			}
 		}
 	}
+	outFile.Close()
 
-	return nil
+	dur, err := audio.Duration(outputPath)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{
+		Boundaries: xunfeiBoundaries(text, progress, audioBytes, dur),
+		Duration:   dur,
+	}, nil
+}
+
+// xunfeiBoundaries maps frame progress to time assuming a constant bitrate,
+// so that a byte of audio always corresponds to the same length of time.
+func xunfeiBoundaries(text string, progress []xunfeiProgress, totalAudio int, duration float64) []Boundary {
+	if totalAudio == 0 {
+		return nil
+	}
+
+	var boundaries []Boundary
+	prevText, prevAudio := 0, 0
+	for _, p := range progress {
+		end := p.TextBytes
+		if end > len(text) {
+			end = len(text)
+		}
+		// Keep span ends on rune boundaries.
+		for end < len(text) && !utf8.RuneStart(text[end]) {
+			end++
+		}
+		if end <= prevText {
+			continue
+		}
+
+		start := float64(prevAudio) / float64(totalAudio) * duration
+		stop := float64(p.AudioBytes) / float64(totalAudio) * duration
+		offset := utf8.RuneCountInString(text[:prevText])
+		for _, b := range EstimateBoundaries(text[prevText:end], stop-start) {
+			b.Offset += offset
+			b.Start += start
+			b.End += start
+			boundaries = append(boundaries, b)
+		}
+		prevText, prevAudio = end, p.AudioBytes
+	}
+	return boundaries
 }
 
 func (x *XunfeiProvider) assembleAuthUrl(hosturl string, apiKey, apiSecret string) string {
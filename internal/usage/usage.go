@@ -0,0 +1,161 @@
+package usage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// dayFormat is the layout of Record.Day.
+const dayFormat = "2006-01-02"
+
+// Record accumulates the TTS usage of one job on one engine on one day.
+type Record struct {
+	Day        string `json:"day"` // Local date, e.g. "2024-05-01"
+	Engine     string `json:"engine"`
+	JobID      string `json:"job_id"`
+	Characters int    `json:"characters"`
+	Requests   int    `json:"requests"`
+
+	// Failed requests are counted apart, as they may or may not have been
+	// billed.
+	FailedCharacters int `json:"failed_characters,omitempty"`
+	FailedRequests   int `json:"failed_requests,omitempty"`
+}
+
+// flushDelay is how long changes are collected before the store is written,
+// so that a render making hundreds of requests writes the file a few times
+// rather than once per request.
+const flushDelay = 2 * time.Second
+
+// Store keeps usage records in a JSON file. Changes are written shortly after
+// they are made, or at once by Flush, so records survive restarts.
+type Store struct {
+	Path string
+
+	mu      sync.Mutex
+	records []Record
+	pending *time.Timer // Set while changes wait to be written
+}
+
+// NewStore loads the records saved at path, if any.
+func NewStore(path string) (*Store, error) {
+	s := &Store{Path: path}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.records); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Add records one request of characters to engine on behalf of jobID, which
+// failed if failed is set. The record is written to disk in the background;
+// failures to do so are logged.
+func (s *Store) Add(jobID string, engine string, characters int, failed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	day := time.Now().Format(dayFormat)
+	i := slices.IndexFunc(s.records, func(r Record) bool {
+		return r.Day == day && r.Engine == engine && r.JobID == jobID
+	})
+	if i < 0 {
+		i = len(s.records)
+		s.records = append(s.records, Record{Day: day, Engine: engine, JobID: jobID})
+	}
+	r := &s.records[i]
+	if failed {
+		r.FailedCharacters += characters
+		r.FailedRequests++
+	} else {
+		r.Characters += characters
+		r.Requests++
+	}
+	if s.pending == nil {
+		s.pending = time.AfterFunc(flushDelay, func() {
+			if err := s.Flush(); err != nil {
+				fmt.Printf("Warning: Failed to save TTS usage: %v\n", err)
+			}
+		})
+	}
+}
+
+// Flush writes pending changes to disk.
+func (s *Store) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.pending == nil {
+		return nil
+	}
+	s.pending.Stop()
+	s.pending = nil
+	return s.save()
+}
+
+// Records returns the records with from <= day < to, oldest first.
+func (s *Store) Records(from, to time.Time) []Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	first, last := from.Format(dayFormat), to.Format(dayFormat)
+	var list []Record
+	for _, r := range s.records {
+		if r.Day >= first && r.Day < last {
+			list = append(list, r)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Day < list[j].Day
+	})
+	return list
+}
+
+// MonthCharacters returns the characters used on engine in the calendar
+// month containing t, including those of failed requests.
+func (s *Store) MonthCharacters(engine string, t time.Time) int {
+	from, to := MonthRange(t)
+	total := 0
+	for _, r := range s.Records(from, to) {
+		if r.Engine == engine {
+			total += r.Characters + r.FailedCharacters
+		}
+	}
+	return total
+}
+
+// MonthRange returns the first day of the month containing t and the first
+// day of the following month.
+func MonthRange(t time.Time) (time.Time, time.Time) {
+	from := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	return from, from.AddDate(0, 1, 0)
+}
+
+func (s *Store) save() error {
+	if dir := filepath.Dir(s.Path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	data, err := json.MarshalIndent(s.records, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write to a temp file first so a crash never leaves a truncated store.
+	tmp := s.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.Path)
+}
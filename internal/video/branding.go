@@ -0,0 +1,190 @@
+package video
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/LeonRhapsody/pptTovideo/internal/audio"
+)
+
+// Watermark positions.
+const (
+	PositionTopLeft     = "top_left"
+	PositionTopRight    = "top_right"
+	PositionBottomLeft  = "bottom_left"
+	PositionBottomRight = "bottom_right"
+	PositionCenter      = "center"
+)
+
+// Branding adds the standard parts of a published video around the slides.
+type Branding struct {
+	// Intro and Outro are video clips played before and after the slides.
+	// They are scaled and padded to the canvas; clips without sound get
+	// silence.
+	Intro string
+	Outro string
+
+	// Watermark is shown over the slides, not over the clips.
+	Watermark *Watermark
+
+	// FadeIn and FadeOut fade the whole video, clips included, from and to
+	// black and silence, in seconds.
+	FadeIn  float64
+	FadeOut float64
+}
+
+// Watermark is an image, typically a logo with transparency, laid over the
+// video.
+type Watermark struct {
+	Path     string
+	Position string  // Default PositionTopRight
+	Opacity  float64 // 0 to 1, default 0.8
+	Margin   int     // Distance from the edges in pixels, default 24
+	Width    float64 // Width relative to the video width, default 0.12
+}
+
+// ValidPosition reports whether p is a known watermark position or empty.
+func ValidPosition(p string) bool {
+	switch p {
+	case "", PositionTopLeft, PositionTopRight, PositionBottomLeft, PositionBottomRight, PositionCenter:
+		return true
+	}
+	return false
+}
+
+// cornerPosition returns the x and y expressions of the overlay filter that
+// place a picture at position, or else fallback, margin pixels from the
+// edges, default 24.
+func cornerPosition(position, fallback string, margin int) (string, string) {
+	if margin <= 0 {
+		margin = 24
+	}
+	left, top := fmt.Sprint(margin), fmt.Sprint(margin)
+	right, bottom := fmt.Sprintf("W-w-%d", margin), fmt.Sprintf("H-h-%d", margin)
+
+	if position == "" {
+		position = fallback
+	}
+	switch position {
+	case PositionTopLeft:
+		return left, top
+	case PositionBottomLeft:
+		return left, bottom
+	case PositionBottomRight:
+		return right, bottom
+	case PositionCenter:
+		return "(W-w)/2", "(H-h)/2"
+	}
+	return right, top
+}
+
+// brandedStreams holds what brand adds to the ffmpeg command.
+type brandedStreams struct {
+	inputs   []string // Input options
+	filter   string   // Filters to append to the graph, starting with ";"
+	video    string   // Labels of the branded streams
+	audio    string
+	duration float64 // Total length including the clips
+}
+
+// brand returns the graph that wraps the main video and audio streams, of
+// length main seconds, in b. Inputs added by it are numbered from input.
+func (b *Branding) brand(video, sound string, main float64, input int, width, height, fps int) (brandedStreams, error) {
+	out := brandedStreams{video: video, audio: sound, duration: main}
+	var f strings.Builder
+
+	if w := b.Watermark; w != nil {
+		opacity := w.Opacity
+		if opacity <= 0 || opacity > 1 {
+			opacity = 0.8
+		}
+		rel := w.Width
+		if rel <= 0 || rel > 1 {
+			rel = 0.12
+		}
+		x, y := cornerPosition(w.Position, PositionTopRight, w.Margin)
+		out.inputs = append(out.inputs, "-i", w.Path)
+		fmt.Fprintf(&f, ";[%d:v]format=rgba,scale=%d:-1,colorchannelmixer=aa=%.3f[wm];%s[wm]overlay=%s:%s:format=auto,format=yuv420p[wmv]",
+			input, max(int(float64(width)*rel), 2), opacity, out.video, x, y)
+		out.video = "[wmv]"
+		input++
+	}
+
+	if b.Intro != "" || b.Outro != "" {
+		// Cut the main streams to their length, since concat plays every
+		// segment to its end.
+		fmt.Fprintf(&f, ";%strim=duration=%.3f,setpts=PTS-STARTPTS[mainv];%saresample=%d,aformat=channel_layouts=stereo,atrim=duration=%.3f,asetpts=PTS-STARTPTS[maina]",
+			out.video, main, out.audio, audio.SampleRate, main)
+
+		clip := func(path, name string) (string, error) {
+			dur, hasAudio, err := probeClip(path)
+			if err != nil {
+				return "", fmt.Errorf("failed to read %s clip: %w", name, err)
+			}
+			out.inputs = append(out.inputs, "-i", path)
+			fmt.Fprintf(&f, ";[%d:v]%s,setsar=1,fps=%d,format=yuv420p,trim=duration=%.3f,setpts=PTS-STARTPTS[%sv]",
+				input, fitCanvas(width, height), fps, dur, name)
+			if hasAudio {
+				fmt.Fprintf(&f, ";[%d:a]aresample=%d,aformat=channel_layouts=stereo,apad,atrim=duration=%.3f,asetpts=PTS-STARTPTS[%sa]",
+					input, audio.SampleRate, dur, name)
+			} else {
+				fmt.Fprintf(&f, ";anullsrc=r=%d:cl=stereo,atrim=duration=%.3f[%sa]", audio.SampleRate, dur, name)
+			}
+			input++
+			out.duration += dur
+			return fmt.Sprintf("[%[1]sv][%[1]sa]", name), nil
+		}
+
+		var parts []string
+		if b.Intro != "" {
+			part, err := clip(b.Intro, "intro")
+			if err != nil {
+				return brandedStreams{}, err
+			}
+			parts = append(parts, part)
+		}
+		parts = append(parts, "[mainv][maina]")
+		if b.Outro != "" {
+			part, err := clip(b.Outro, "outro")
+			if err != nil {
+				return brandedStreams{}, err
+			}
+			parts = append(parts, part)
+		}
+		fmt.Fprintf(&f, ";%sconcat=n=%d:v=1:a=1[catv][cata]", strings.Join(parts, ""), len(parts))
+		out.video, out.audio = "[catv]", "[cata]"
+	}
+
+	if b.FadeIn > 0 || b.FadeOut > 0 {
+		var vf, af []string
+		if b.FadeIn > 0 {
+			vf = append(vf, fmt.Sprintf("fade=t=in:st=0:d=%.3f", b.FadeIn))
+			af = append(af, fmt.Sprintf("afade=t=in:st=0:d=%.3f", b.FadeIn))
+		}
+		if b.FadeOut > 0 {
+			start := max(out.duration-b.FadeOut, 0)
+			vf = append(vf, fmt.Sprintf("fade=t=out:st=%.3f:d=%.3f", start, b.FadeOut))
+			af = append(af, fmt.Sprintf("afade=t=out:st=%.3f:d=%.3f", start, b.FadeOut))
+		}
+		fmt.Fprintf(&f, ";%s%s[fadev];%s%s[fadea]", out.video, strings.Join(vf, ","), out.audio, strings.Join(af, ","))
+		out.video, out.audio = "[fadev]", "[fadea]"
+	}
+
+	out.filter = f.String()
+	return out, nil
+}
+
+// probeClip returns the duration of a video clip and whether it has sound.
+func probeClip(path string) (float64, bool, error) {
+	dur, err := audio.Duration(path)
+	if err != nil {
+		return 0, false, err
+	}
+	out, err := exec.Command("ffprobe", "-v", "error", "-select_streams", "a",
+		"-show_entries", "stream=index", "-of", "csv=p=0", path).Output()
+	if err != nil {
+		return 0, false, err
+	}
+	return dur, strings.TrimSpace(string(out)) != "", nil
+}
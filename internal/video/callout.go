@@ -0,0 +1,84 @@
+package video
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+)
+
+// Callout effects.
+const (
+	CalloutHighlight = "highlight" // Frame the region and dim the rest of the slide
+	CalloutZoom      = "zoom"      // Zoom into the region and back out
+)
+
+// Callout draws attention to a region of the slide for one segment.
+type Callout struct {
+	Effect string
+	Region Region
+}
+
+// calloutFade is how long highlights take to appear and disappear, in seconds.
+const calloutFade = 0.3
+
+var (
+	highlightColor = color.RGBA{255, 196, 0, 255}
+	spotlightShade = color.RGBA{0, 0, 0, 110} // Premultiplied, about 43% black
+)
+
+// highlightRun is a highlight overlay shown from Start for Duration seconds.
+type highlightRun struct {
+	Path     string
+	Start    float64
+	Duration float64
+}
+
+// filter returns the filters that fade the overlay of r in and out at its
+// time in the video.
+func (r highlightRun) filter(input int) string {
+	fade := min(calloutFade, r.Duration/3)
+	return fmt.Sprintf("[%d:v]format=rgba,fade=t=in:st=0:d=%.3f:alpha=1,fade=t=out:st=%.3f:d=%.3f:alpha=1,setpts=PTS-STARTPTS+%.3f/TB",
+		input, fade, r.Duration-fade, fade, r.Start)
+}
+
+// overlay returns the overlay filter that shows the overlay of r, labeled
+// highlight, over the stream labeled base.
+func (r highlightRun) overlay(base, highlight string) string {
+	return fmt.Sprintf("%s%soverlay=0:0:eof_action=pass:enable=between(t\\,%.3f\\,%.3f)",
+		base, highlight, r.Start, r.Start+r.Duration)
+}
+
+// DrawHighlightOverlay draws a spotlight on rect onto a transparent canvas of
+// the given size and saves it as a PNG at dstPath: everything outside rect is
+// dimmed and rect is framed.
+func DrawHighlightOverlay(dstPath string, width, height int, rect image.Rectangle) error {
+	rgba := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	// Frame a little outside the region, so that the frame does not cover it.
+	thickness := max(height/180, 3)
+	frame := rect.Inset(-2 * thickness).Intersect(rgba.Bounds())
+
+	draw.Draw(rgba, rgba.Bounds(), image.NewUniform(spotlightShade), image.Point{}, draw.Src)
+	draw.Draw(rgba, frame, image.Transparent, image.Point{}, draw.Src)
+
+	edge := image.NewUniform(highlightColor)
+	for _, r := range []image.Rectangle{
+		image.Rect(frame.Min.X, frame.Min.Y, frame.Max.X, frame.Min.Y+thickness),
+		image.Rect(frame.Min.X, frame.Max.Y-thickness, frame.Max.X, frame.Max.Y),
+		image.Rect(frame.Min.X, frame.Min.Y, frame.Min.X+thickness, frame.Max.Y),
+		image.Rect(frame.Max.X-thickness, frame.Min.Y, frame.Max.X, frame.Max.Y),
+	} {
+		draw.Draw(rgba, r, edge, image.Point{}, draw.Src)
+	}
+
+	outFile, err := os.Create(dstPath)
+	if err != nil {
+		return err
+	}
+	defer outFile.Close()
+
+	return png.Encode(outFile, rgba)
+}
@@ -0,0 +1,172 @@
+package video
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Canvas aspect presets.
+const (
+	Aspect16x9 = "16:9"
+	Aspect9x16 = "9:16" // Short-video platforms
+	Aspect1x1  = "1:1"
+	Aspect4x3  = "4:3"
+)
+
+// Layouts of a slide on a canvas of a different aspect ratio.
+const (
+	LayoutLetterbox = "letterbox" // Fit the slide and pad with black
+	LayoutBlur      = "blur"      // Fit the slide over a blurred, enlarged copy of itself
+	LayoutCrop      = "crop"      // Fill the canvas with a part of the slide
+)
+
+// Canvas is the frame of the output video. Without one, the video has the
+// size of the first slide.
+type Canvas struct {
+	// Size is an aspect preset such as "9:16", whose short side is the
+	// resolution of the encoding profile, or exact dimensions such as
+	// "1080x1350".
+	Size   string  `json:"size"`
+	Layout string  `json:"layout,omitempty"` // Default LayoutLetterbox
+	Region *Region `json:"region,omitempty"` // Part of the slide kept by LayoutCrop, default all of it
+}
+
+// Validate reports whether the size and layout of c are understood.
+func (c *Canvas) Validate() error {
+	if c == nil {
+		return nil
+	}
+	if _, _, err := c.dimensions(0); err != nil {
+		return err
+	}
+	switch c.Layout {
+	case "", LayoutLetterbox, LayoutBlur, LayoutCrop:
+	default:
+		return fmt.Errorf("unknown canvas layout %q", c.Layout)
+	}
+	if c.Region != nil && !c.Region.Valid() {
+		return fmt.Errorf("canvas region must lie within the slide")
+	}
+	return nil
+}
+
+// dimensions returns the even width and height of the canvas with a short
+// side of resolution pixels, default 1080, for presets.
+func (c *Canvas) dimensions(resolution int) (int, int, error) {
+	if w, h, ok := strings.Cut(c.Size, "x"); ok {
+		width, err1 := strconv.Atoi(w)
+		height, err2 := strconv.Atoi(h)
+		if err1 != nil || err2 != nil || width < 16 || height < 16 || width > 7680 || height > 7680 {
+			return 0, 0, fmt.Errorf("invalid canvas size %q", c.Size)
+		}
+		return width + width%2, height + height%2, nil
+	}
+
+	short := 1080
+	if resolution > 0 {
+		short = resolution
+	}
+	// Long sides are rounded to even numbers for the encoder.
+	long := func(num, den int) int {
+		l := short * num / den
+		return l + l%2
+	}
+	switch c.Size {
+	case Aspect16x9:
+		return long(16, 9), short, nil
+	case Aspect9x16:
+		return short, long(16, 9), nil
+	case Aspect1x1:
+		return short, short, nil
+	case Aspect4x3:
+		return long(4, 3), short, nil
+	}
+	return 0, 0, fmt.Errorf("unknown canvas size %q", c.Size)
+}
+
+// fit returns a filter graph that lays out the stream labeled in on a canvas
+// of width x height. tag keeps the labels of the graph unique among
+// several slides. A nil canvas letterboxes.
+func (c *Canvas) fit(in string, tag string, width, height int) string {
+	layout := LayoutLetterbox
+	if c != nil && c.Layout != "" {
+		layout = c.Layout
+	}
+
+	switch layout {
+	case LayoutBlur:
+		// The background is blurred at a quarter of the size, which looks the
+		// same and is much cheaper.
+		bw, bh := max(width/4, 2), max(height/4, 2)
+		return fmt.Sprintf("%ssplit[%[2]sbg][%[2]sfg];"+
+			"[%[2]sbg]scale=%[3]d:%[4]d:force_original_aspect_ratio=increase,crop=%[3]d:%[4]d,boxblur=10:2,scale=%[5]d:%[6]d,setsar=1[%[2]sblur];"+
+			"[%[2]sfg]scale=%[5]d:%[6]d:force_original_aspect_ratio=decrease,setsar=1[%[2]sfit];"+
+			"[%[2]sblur][%[2]sfit]overlay=(W-w)/2:(H-h)/2",
+			in, tag, bw, bh, width, height)
+	case LayoutCrop:
+		// Crop the smallest area of the canvas aspect that contains the
+		// region, centered on it where the slide allows.
+		r := Region{X: 0, Y: 0, W: 1, H: 1}
+		if c.Region != nil {
+			r = *c.Region
+		}
+		aspect := float64(width) / float64(height)
+		cw := fmt.Sprintf("min(iw\\,min(ih*%[1]g\\,max(iw*%[2]g\\,ih*%[3]g*%[1]g)))", aspect, r.W, r.H)
+		return fmt.Sprintf("%scrop=w=%s:h=ow/%g:x=max(0\\,min(iw*%g-ow/2\\,iw-ow)):y=max(0\\,min(ih*%g-oh/2\\,ih-oh)),scale=%d:%d",
+			in, cw, aspect, r.X+r.W/2, r.Y+r.H/2, width, height)
+	}
+	return in + fitCanvas(width, height)
+}
+
+// place returns where region r of a slide of srcW x srcH pixels ends up on a
+// canvas of width x height, following the layout of c.
+func (c *Canvas) place(r Region, srcW, srcH, width, height int) image.Rectangle {
+	sw, sh := float64(srcW), float64(srcH)
+	w, h := float64(width), float64(height)
+
+	// The slide is scaled by scale and its top left corner lands at x, y.
+	var scale, x, y float64
+	if c != nil && c.Layout == LayoutCrop {
+		crop := Region{X: 0, Y: 0, W: 1, H: 1}
+		if c.Region != nil {
+			crop = *c.Region
+		}
+		aspect := w / h
+		cw := math.Min(sw, math.Min(sh*aspect, math.Max(sw*crop.W, sh*crop.H*aspect)))
+		ch := cw / aspect
+		cx := math.Max(0, math.Min(sw*(crop.X+crop.W/2)-cw/2, sw-cw))
+		cy := math.Max(0, math.Min(sh*(crop.Y+crop.H/2)-ch/2, sh-ch))
+		scale = w / cw
+		x, y = -cx*scale, -cy*scale
+	} else {
+		scale = math.Min(w/sw, h/sh)
+		x, y = (w-sw*scale)/2, (h-sh*scale)/2
+	}
+
+	return image.Rect(
+		int(x+r.X*sw*scale), int(y+r.Y*sh*scale),
+		int(x+(r.X+r.W)*sw*scale), int(y+(r.Y+r.H)*sh*scale),
+	).Intersect(image.Rect(0, 0, width, height))
+}
+
+// placeMotion returns m with its region, given on a slide of srcW x srcH
+// pixels, moved to where the layout of c shows it on a canvas of width x
+// height: the camera moves over the slide after it has been laid out. A
+// region the layout cuts off leaves the slide still.
+func (c *Canvas) placeMotion(m *Motion, srcW, srcH, width, height int) *Motion {
+	if m == nil || m.Region == nil {
+		return m
+	}
+	rect := c.place(*m.Region, srcW, srcH, width, height)
+	placed := *m
+	placed.Region = &Region{
+		X: float64(rect.Min.X) / float64(width),
+		Y: float64(rect.Min.Y) / float64(height),
+		W: float64(rect.Dx()) / float64(width),
+		H: float64(rect.Dy()) / float64(height),
+	}
+	return &placed
+}
@@ -0,0 +1,66 @@
+package video
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// Chapter is a titled part of the video, in seconds from its start.
+type Chapter struct {
+	Title string  `json:"title"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// metadataEscaper escapes the characters that are special in ffmetadata files.
+var metadataEscaper = strings.NewReplacer(`\`, `\\`, "=", `\=`, ";", `\;`, "#", `\#`, "\n", "\\\n")
+
+// writeFFMetadata writes chapters as an ffmetadata file, which ffmpeg muxes
+// into MP4 and Matroska files as chapter markers.
+func writeFFMetadata(path string, chapters []Chapter) error {
+	var b strings.Builder
+	b.WriteString(";FFMETADATA1\n")
+	for _, c := range chapters {
+		fmt.Fprintf(&b, "[CHAPTER]\nTIMEBASE=1/1000\nSTART=%d\nEND=%d\ntitle=%s\n",
+			int64(math.Round(c.Start*1000)), int64(math.Round(c.End*1000)), metadataEscaper.Replace(c.Title))
+	}
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
+
+// ChapterDescription lists chapters as timestamped lines, the format video
+// platforms such as YouTube turn into chapters when it is pasted into a
+// video description.
+func ChapterDescription(chapters []Chapter) string {
+	long := len(chapters) > 0 && chapters[len(chapters)-1].Start >= 3600
+	var b strings.Builder
+	for _, c := range chapters {
+		s := int(c.Start)
+		if long {
+			fmt.Fprintf(&b, "%d:%02d:%02d %s\n", s/3600, s/60%60, s%60, c.Title)
+		} else {
+			fmt.Fprintf(&b, "%d:%02d %s\n", s/60, s%60, c.Title)
+		}
+	}
+	return b.String()
+}
+
+// ClipDurations returns the lengths of the intro and outro clips of b, 0 for
+// those it does not have.
+func (b *Branding) ClipDurations() (intro, outro float64, err error) {
+	if b == nil {
+		return 0, 0, nil
+	}
+	if b.Intro != "" {
+		if intro, _, err = probeClip(b.Intro); err != nil {
+			return 0, 0, err
+		}
+	}
+	if b.Outro != "" {
+		if outro, _, err = probeClip(b.Outro); err != nil {
+			return 0, 0, err
+		}
+	}
+	return intro, outro, nil
+}
@@ -1,21 +1,20 @@
 package video
 
 import (
-	"fmt"
 	"image"
-	"image/color"
 	"image/draw"
 	"image/jpeg"
-	"io/ioutil"
+	"image/png"
 	"os"
 
-	"github.com/golang/freetype"
-	"github.com/golang/freetype/truetype"
+	"github.com/LeonRhapsody/pptTovideo/internal/config"
 	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
 )
 
-// DrawSubtitle draws text onto the image at srcPath and saves it to dstPath.
-func DrawSubtitle(srcPath, dstPath, text string, fontSize int) error {
+// DrawSubtitle draws text in the given style onto the image at srcPath and
+// saves it to dstPath.
+func DrawSubtitle(srcPath, dstPath, text string, style config.SubtitleStyle) error {
 	// 1. Load Image
 	imgFile, err := os.Open(srcPath)
 	if err != nil {
@@ -31,100 +30,103 @@ func DrawSubtitle(srcPath, dstPath, text string, fontSize int) error {
 	rgba := image.NewRGBA(img.Bounds())
 	draw.Draw(rgba, rgba.Bounds(), img, image.Point{}, draw.Src)
 
-	// 2. Load Font
-	fontPath := GetBestFontPath()
-	if fontPath == "" {
-		return fmt.Errorf("no suitable font found for subtitles")
+	if err := drawSubtitleText(rgba, text, style); err != nil {
+		return err
 	}
 
-	b, err := ioutil.ReadFile(fontPath)
+	// 5. Save
+	outFile, err := os.Create(dstPath)
 	if err != nil {
-		return fmt.Errorf("failed to read font %s: %v", fontPath, err)
+		return err
+	}
+	defer outFile.Close()
+
+	return jpeg.Encode(outFile, rgba, nil)
+}
+
+// DrawSubtitleOverlay draws text in the given style onto a transparent canvas
+// of the given size and saves it as a PNG at dstPath, to be overlaid on the
+// video.
+func DrawSubtitleOverlay(dstPath string, width, height int, text string, style config.SubtitleStyle) error {
+	rgba := image.NewRGBA(image.Rect(0, 0, width, height))
+	if text != "" {
+		if err := drawSubtitleText(rgba, text, style); err != nil {
+			return err
+		}
 	}
 
-	loadedFont, err := truetype.Parse(b)
+	outFile, err := os.Create(dstPath)
 	if err != nil {
-		return fmt.Errorf("failed to parse font: %v", err)
+		return err
 	}
+	defer outFile.Close()
 
-	// 3. Setup Context
-	c := freetype.NewContext()
-	c.SetDPI(72)
-	c.SetFont(loadedFont)
-	c.SetFontSize(float64(fontSize)) // Dynamic size
-	c.SetClip(rgba.Bounds())
-	c.SetDst(rgba)
-	c.SetSrc(image.NewUniform(color.White))
-
-	// 4. Measure & Wrap Text
-	// Create a face to measure width
-	opts := truetype.Options{Size: float64(fontSize), DPI: 72}
-	face := truetype.NewFace(loadedFont, &opts)
-
-	// Max width: 90% of image width
-	maxWidth := int(float64(rgba.Bounds().Dx()) * 0.9)
-	paddingX := (rgba.Bounds().Dx() - maxWidth) / 2
-
-	var lines []string
-	var currentLine string
-
-	runes := []rune(text)
-	for _, r := range runes {
-		testLine := currentLine + string(r)
-		width := measureStringWidth(face, testLine)
-		if width > maxWidth && len(currentLine) > 0 {
-			lines = append(lines, currentLine)
-			currentLine = string(r)
-		} else {
-			currentLine = testLine
-		}
+	return png.Encode(outFile, rgba)
+}
+
+// drawSubtitleText draws text onto rgba in the given style.
+func drawSubtitleText(rgba *image.RGBA, text string, s config.SubtitleStyle) error {
+	style, err := resolveSubtitleStyle(s)
+	if err != nil {
+		return err
 	}
-	if len(currentLine) > 0 {
-		lines = append(lines, currentLine)
+
+	// 2. Load the font, with fallbacks for the glyphs of text it lacks.
+	face, err := newSubtitleFace(style.FontFamily, float64(style.FontSize), text)
+	if err != nil {
+		return err
 	}
+	defer face.Close()
 
-	// 5. Draw Lines (Bottom Up)
-	lineHeight := int(float64(fontSize) * 1.5)
-	totalHeight := len(lines) * lineHeight
-	startY := rgba.Bounds().Dy() - totalHeight - 50 // Bottom margin
+	// 3. Text is drawn into a mask first, which is grown into the outline.
+	bounds := rgba.Bounds()
+	mask := image.NewAlpha(bounds)
+	d := font.Drawer{Dst: mask, Src: image.Opaque, Face: face}
 
+	// 4. Wrap Text
+	marginH := style.MarginH
+	if marginH <= 0 {
+		marginH = bounds.Dx() / 20
+	}
+	maxWidth := bounds.Dx() - 2*marginH
+	lines := style.limitLines(face, wrapText(face, text, maxWidth), maxWidth)
+
+	// 5. Lay out the lines, centered vertically in their line height.
+	metrics := face.Metrics()
+	ascent, descent := metrics.Ascent.Ceil(), metrics.Descent.Ceil()
+	lineHeight := int(float64(style.FontSize) * style.LineHeight)
+	top := style.top(len(lines)*lineHeight, bounds.Dy())
+
+	var textRect image.Rectangle
 	for i, line := range lines {
-		// Center text
 		lineWidth := measureStringWidth(face, line)
-		x := (rgba.Bounds().Dx() - lineWidth) / 2
-		// If calculation fails, fallback to padding
-		if x < paddingX {
-			x = paddingX
-		}
-
-		y := startY + (i+1)*lineHeight
-		pt := freetype.Pt(x, y)
-
-		// Shadow
-		c.SetSrc(image.NewUniform(color.Black))
-		offset := 2
-		for dy := -offset; dy <= offset; dy++ {
-			for dx := -offset; dx <= offset; dx++ {
-				if dx == 0 && dy == 0 {
-					continue
-				}
-				c.DrawString(line, freetype.Pt(pt.X.Ceil()+dx, pt.Y.Ceil()+dy))
-			}
+		x := marginH
+		switch style.Align {
+		case AlignCenter:
+			x = max((bounds.Dx()-lineWidth)/2, marginH)
+		case AlignRight:
+			x = bounds.Dx() - marginH - lineWidth
 		}
+		y := top + i*lineHeight + (lineHeight-ascent-descent)/2 + ascent
 
-		// Text
-		c.SetSrc(image.NewUniform(color.White))
-		c.DrawString(line, pt)
+		d.Dot = fixed.P(x, y)
+		d.DrawString(line)
+		textRect = textRect.Union(image.Rect(x, y-ascent, x+lineWidth, y+descent))
 	}
 
-	// 5. Save
-	outFile, err := os.Create(dstPath)
-	if err != nil {
-		return err
+	// 6. Composite background, outline and text.
+	if style.background != nil {
+		pad := style.FontSize / 3
+		box := textRect.Inset(-pad).Intersect(bounds)
+		draw.Draw(rgba, box, image.NewUniform(*style.background), image.Point{}, draw.Over)
 	}
-	defer outFile.Close()
-
-	return jpeg.Encode(outFile, rgba, nil)
+	if style.OutlineWidth > 0 {
+		area := textRect.Inset(-style.OutlineWidth).Intersect(bounds)
+		outline := dilate(mask, area, style.OutlineWidth)
+		draw.DrawMask(rgba, area, image.NewUniform(style.outline), image.Point{}, outline, area.Min, draw.Over)
+	}
+	draw.DrawMask(rgba, textRect, image.NewUniform(style.color), image.Point{}, mask, textRect.Min, draw.Over)
+	return nil
 }
 
 func measureStringWidth(face font.Face, text string) int {
@@ -0,0 +1,117 @@
+package video
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/LeonRhapsody/pptTovideo/internal/config"
+)
+
+// defaultFrameRate of the composed video. Slides are still images, so a
+// modest rate is enough and keeps the timeline quantization below 40ms.
+const defaultFrameRate = 25
+
+// minTargetBitrate keeps a target file size that is too small for the length
+// of the video from producing an unwatchable encode.
+const minTargetBitrate = 100e3
+
+// frameRate returns the frame rate of profile p.
+func frameRate(p config.EncodingProfile) int {
+	if p.FPS > 0 {
+		return p.FPS
+	}
+	return defaultFrameRate
+}
+
+// encoderArgs returns the ffmpeg output options that encode a video of
+// duration seconds as profile p describes. still tells whether the video only
+// shows still images, with no camera motion or video clips.
+func encoderArgs(p config.EncodingProfile, duration float64, still bool) ([]string, error) {
+	codec := p.VideoCodec
+	if codec == "" {
+		codec = "libx264"
+	}
+	audioCodec := p.AudioCodec
+	if audioCodec == "" {
+		audioCodec = "aac"
+	}
+	audioBitrate := p.AudioBitrate
+	if audioBitrate == "" {
+		audioBitrate = "192k"
+	}
+
+	args := []string{"-c:v", codec}
+	switch {
+	case p.Tune == "none":
+	case p.Tune != "":
+		args = append(args, "-tune", p.Tune)
+	case codec == "libx264" && still:
+		args = append(args, "-tune", "stillimage")
+	}
+	if p.Preset != "" {
+		args = append(args, "-preset", p.Preset)
+	}
+
+	switch {
+	case p.TargetSizeMB > 0:
+		audioRate, err := parseBitrate(audioBitrate)
+		if err != nil {
+			return nil, err
+		}
+		// Leave a few percent for the container.
+		rate := max(p.TargetSizeMB*8e6*0.97/max(duration, 1)-audioRate, minTargetBitrate)
+		bitrate := strconv.FormatFloat(rate/1000, 'f', 0, 64) + "k"
+		bufsize := strconv.FormatFloat(rate*2/1000, 'f', 0, 64) + "k"
+		args = append(args, "-b:v", bitrate, "-maxrate", bitrate, "-bufsize", bufsize)
+	case p.CRF > 0:
+		args = append(args, "-crf", strconv.Itoa(p.CRF))
+		if codec == "libvpx-vp9" {
+			args = append(args, "-b:v", "0")
+		}
+	case p.Bitrate != "":
+		args = append(args, "-b:v", p.Bitrate)
+	default:
+		args = append(args, "-crf", "23")
+	}
+
+	if p.GOP > 0 {
+		args = append(args, "-g", strconv.Itoa(p.GOP))
+	}
+	args = append(args,
+		"-r", strconv.Itoa(frameRate(p)),
+		"-c:a", audioCodec, "-b:a", audioBitrate)
+	if !p.DisableFastStart {
+		args = append(args, "-movflags", "+faststart")
+	}
+	return args, nil
+}
+
+// parseBitrate parses a bitrate such as "192k" or "2.5M" in bits per second.
+func parseBitrate(s string) (float64, error) {
+	scale := 1.0
+	num := strings.TrimSpace(s)
+	switch {
+	case strings.HasSuffix(num, "k"), strings.HasSuffix(num, "K"):
+		scale, num = 1e3, num[:len(num)-1]
+	case strings.HasSuffix(num, "M"):
+		scale, num = 1e6, num[:len(num)-1]
+	}
+	v, err := strconv.ParseFloat(num, 64)
+	if err != nil || v <= 0 {
+		return 0, fmt.Errorf("invalid bitrate %q", s)
+	}
+	return v * scale, nil
+}
+
+// scaleToResolution scales width x height so that the short side is
+// resolution pixels, keeping dimensions even. A resolution of 0 keeps the
+// size.
+func scaleToResolution(width, height, resolution int) (int, int) {
+	if resolution > 0 {
+		short := min(width, height)
+		width = int(float64(width)*float64(resolution)/float64(short) + 0.5)
+		height = int(float64(height)*float64(resolution)/float64(short) + 0.5)
+	}
+	return width + width%2, height + height%2
+}
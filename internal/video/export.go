@@ -0,0 +1,166 @@
+package video
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Output formats. The composed MP4 is always produced; the others are
+// converted from it.
+const (
+	FormatMP4    = "mp4"    // H.264/AAC
+	FormatWebM   = "webm"   // VP9/Opus
+	FormatHLS    = "hls"    // Playlist and MPEG-TS segments, for streaming
+	FormatGIF    = "gif"    // Short animated teaser
+	FormatTeaser = "teaser" // Short MP4 teaser
+	FormatM4A    = "m4a"    // Narration only, AAC
+	FormatMP3    = "mp3"    // Narration only, MP3
+)
+
+// Formats lists every supported output format.
+var Formats = []string{FormatMP4, FormatWebM, FormatHLS, FormatGIF, FormatTeaser, FormatM4A, FormatMP3}
+
+// DefaultTeaserDuration is the length of GIF and MP4 teasers in seconds.
+const DefaultTeaserDuration = 10.0
+
+// ExportOptions tunes the converted outputs.
+type ExportOptions struct {
+	TeaserDuration float64 // Seconds from the start, default DefaultTeaserDuration
+	TeaserWidth    int     // Default 480 for GIF and 720 for MP4
+}
+
+// Export converts the composed video at master into format and returns the
+// path of the result, which is next to master and shares its name. For HLS
+// it is the playlist, with the segments in the same directory.
+func Export(master string, format string, opts ExportOptions) (string, error) {
+	base := strings.TrimSuffix(master, filepath.Ext(master))
+	teaser := opts.TeaserDuration
+	if teaser <= 0 {
+		teaser = DefaultTeaserDuration
+	}
+	length := strconv.FormatFloat(teaser, 'f', 3, 64)
+
+	var out string
+	var args []string
+	switch format {
+	case FormatMP4:
+		return master, nil
+	case FormatWebM:
+		out = base + ".webm"
+		args = []string{"-i", master,
+			"-c:v", "libvpx-vp9", "-crf", "33", "-b:v", "0", "-row-mt", "1", "-deadline", "good", "-cpu-used", "4",
+			"-c:a", "libopus", "-b:a", "128k",
+			"-y", out}
+	case FormatHLS:
+		dir := base + "_hls"
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", err
+		}
+		out = filepath.Join(dir, "index.m3u8")
+		videoCodec, audioCodec, err := streamCodecs(master)
+		if err != nil {
+			return "", err
+		}
+		// H.264/AAC can be segmented without re-encoding; other codecs of
+		// the encoding profile are converted to them, with a keyframe at
+		// every segment. MPEG-TS cannot carry the MP4 subtitle tracks.
+		args = []string{"-i", master, "-c:v", "copy"}
+		if videoCodec != "h264" {
+			args = []string{"-i", master,
+				"-c:v", "libx264", "-crf", "20", "-preset", "veryfast", "-pix_fmt", "yuv420p",
+				"-force_key_frames", "expr:gte(t,n_forced*6)"}
+		}
+		if audioCodec == "aac" || audioCodec == "mp3" {
+			args = append(args, "-c:a", "copy")
+		} else {
+			args = append(args, "-c:a", "aac", "-b:a", "192k")
+		}
+		args = append(args, "-sn",
+			"-f", "hls", "-hls_time", "6", "-hls_playlist_type", "vod",
+			"-hls_segment_filename", filepath.Join(dir, "segment_%03d.ts"),
+			"-y", out)
+	case FormatGIF:
+		out = base + "_teaser.gif"
+		width := opts.TeaserWidth
+		if width <= 0 {
+			width = 480
+		}
+		// A palette computed from the clip itself keeps the GIF from banding.
+		args = []string{"-t", length, "-i", master,
+			"-filter_complex", fmt.Sprintf("fps=10,scale=%d:-1:flags=lanczos,split[a][b];[a]palettegen=stats_mode=diff[p];[b][p]paletteuse=dither=bayer", width),
+			"-loop", "0",
+			"-y", out}
+	case FormatTeaser:
+		out = base + "_teaser.mp4"
+		width := opts.TeaserWidth
+		if width <= 0 {
+			width = 720
+		}
+		args = []string{"-t", length, "-i", master,
+			"-vf", fmt.Sprintf("scale=%d:-2", width),
+			"-c:v", "libx264", "-crf", "26", "-preset", "veryfast", "-pix_fmt", "yuv420p",
+			"-c:a", "aac", "-b:a", "128k",
+			"-af", fmt.Sprintf("afade=t=out:st=%s:d=1", strconv.FormatFloat(max(teaser-1, 0), 'f', 3, 64)),
+			"-movflags", "+faststart",
+			"-y", out}
+	case FormatM4A:
+		out = base + ".m4a"
+		_, audioCodec, err := streamCodecs(master)
+		if err != nil {
+			return "", err
+		}
+		// AAC is copied; other codecs of the encoding profile, such as
+		// Opus, are converted to it.
+		args = []string{"-i", master, "-vn", "-sn", "-c:a", "copy"}
+		if audioCodec != "aac" {
+			args = []string{"-i", master, "-vn", "-sn", "-c:a", "aac", "-b:a", "192k"}
+		}
+		args = append(args, "-movflags", "+faststart", "-y", out)
+	case FormatMP3:
+		out = base + ".mp3"
+		args = []string{"-i", master, "-vn", "-sn", "-c:a", "libmp3lame", "-q:a", "2", "-y", out}
+	default:
+		return "", fmt.Errorf("unsupported output format %q", format)
+	}
+
+	cmd := exec.Command("ffmpeg", append([]string{"-hide_banner", "-nostdin"}, args...)...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("ffmpeg failed: %w, output: %s", err, string(output))
+	}
+	return out, nil
+}
+
+// streamCodecs returns the codecs of the first video and audio streams of
+// path, such as "h264" and "aac".
+func streamCodecs(path string) (string, string, error) {
+	out, err := exec.Command("ffprobe", "-v", "error",
+		"-show_entries", "stream=codec_type,codec_name", "-of", "json", path).Output()
+	if err != nil {
+		return "", "", fmt.Errorf("ffprobe failed: %w", err)
+	}
+	var probe struct {
+		Streams []struct {
+			CodecType string `json:"codec_type"`
+			CodecName string `json:"codec_name"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return "", "", err
+	}
+
+	var videoCodec, audioCodec string
+	for _, s := range probe.Streams {
+		switch {
+		case s.CodecType == "video" && videoCodec == "":
+			videoCodec = s.CodecName
+		case s.CodecType == "audio" && audioCodec == "":
+			audioCodec = s.CodecName
+		}
+	}
+	return videoCodec, audioCodec, nil
+}
@@ -1,26 +1,539 @@
 package video
 
 import (
+	"encoding/xml"
+	"fmt"
+	"image"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
 )
 
-// GetBestFontPath returns the path to the best available font for Chinese text.
-// Priority: STHeiti -> PingFang -> Arial -> Fallback
-func GetBestFontPath() string {
-	candidates := []string{
-		"msyh.ttf", // Local file has highest priority
-		"/Users/leon/Documents/02-code/go/src/github.com/LeonRhapsody/pptTovideo/msyh.ttf",
-		"/System/Library/Fonts/STHeiti Medium.ttc",
-		"/System/Library/Fonts/STHeiti Light.ttc",
-		"/System/Library/Fonts/PingFang.ttc",
-		"/Library/Fonts/Microsoft YaHei.ttf", // If user installs it
-		"/System/Library/Fonts/Supplemental/Arial.ttf",
+// FontExtensions are the font files discovered and accepted for upload.
+// Collections (.ttc, .otc) hold several fonts, usually the weights or
+// regional variants of one family.
+var FontExtensions = []string{".ttf", ".otf", ".ttc", ".otc"}
+
+// preferredFonts are families known to cover Chinese text, best first. The
+// first one installed is the default subtitle font; the others are tried
+// first for glyphs a font lacks.
+var preferredFonts = []string{
+	"Microsoft YaHei",
+	"PingFang SC",
+	"Heiti SC",
+	"STHeiti",
+	"Noto Sans CJK SC",
+	"Source Han Sans SC",
+	"WenQuanYi Zen Hei",
+	"WenQuanYi Micro Hei",
+	"Noto Sans",
+	"DejaVu Sans",
+	"Arial Unicode MS",
+	"Arial",
+}
+
+// localFonts are font files in the working directory that are used before
+// installed ones.
+var localFonts = []string{"msyh.ttf"}
+
+// FontInfo describes a font that subtitles can use.
+type FontInfo struct {
+	Family string `json:"family"`
+	Style  string `json:"style"`
+	Path   string `json:"path"`
+	Index  int    `json:"index"` // Of the font in a collection
+}
+
+// fontCatalog indexes the fonts found on the system. Fonts are parsed when
+// they are first used.
+type fontCatalog struct {
+	mu      sync.Mutex
+	scanned bool
+	dirs    []string // Registered in addition to the system ones
+	entries []FontInfo
+	loaded  map[FontInfo]*sfnt.Font
+	// fallbacks caches which entry covers a rune, -1 for none.
+	fallbacks map[rune]int
+}
+
+var fonts = &fontCatalog{loaded: make(map[FontInfo]*sfnt.Font), fallbacks: make(map[rune]int)}
+
+// AddFontDir makes the fonts in dir available, such as a directory of
+// uploaded brand fonts.
+func AddFontDir(dir string) {
+	fonts.mu.Lock()
+	defer fonts.mu.Unlock()
+
+	if slices.Contains(fonts.dirs, dir) {
+		return
+	}
+	fonts.dirs = append(fonts.dirs, dir)
+	if fonts.scanned {
+		fonts.scanDir(dir)
+	}
+}
+
+// RegisterFont adds the font file at path, or replaces the fonts previously
+// found in it, and returns the fonts it holds.
+func RegisterFont(path string) ([]FontInfo, error) {
+	infos, err := readFontInfo(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fonts.mu.Lock()
+	defer fonts.mu.Unlock()
+
+	fonts.scan()
+	fonts.entries = slices.DeleteFunc(fonts.entries, func(e FontInfo) bool { return e.Path == path })
+	for e := range fonts.loaded {
+		if e.Path == path {
+			delete(fonts.loaded, e)
+		}
+	}
+	clear(fonts.fallbacks)
+	fonts.entries = append(infos, fonts.entries...)
+	return infos, nil
+}
+
+// CheckFont reports whether the file at path holds fonts that subtitles can
+// use, without adding them.
+func CheckFont(path string) error {
+	_, err := readFontInfo(path)
+	return err
+}
+
+// ListFonts returns the fonts subtitles can use.
+func ListFonts() []FontInfo {
+	fonts.mu.Lock()
+	defer fonts.mu.Unlock()
+
+	fonts.scan()
+	return slices.Clone(fonts.entries)
+}
+
+// scan indexes the fonts of all font directories the first time it is
+// called.
+func (c *fontCatalog) scan() {
+	if c.scanned {
+		return
+	}
+	c.scanned = true
+
+	for _, path := range localFonts {
+		if infos, err := readFontInfo(path); err == nil {
+			c.entries = append(c.entries, infos...)
+		}
+	}
+	for _, dir := range c.dirs {
+		c.scanDir(dir)
+	}
+	for _, dir := range systemFontDirs() {
+		c.scanDir(dir)
+	}
+}
+
+func (c *fontCatalog) scanDir(dir string) {
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		// Hidden directories hold uploads that are still being checked.
+		if err == nil && d.IsDir() && path != dir && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if err != nil || d.IsDir() || !slices.Contains(FontExtensions, strings.ToLower(filepath.Ext(path))) {
+			return nil
+		}
+		if infos, err := readFontInfo(path); err == nil {
+			c.entries = append(c.entries, infos...)
+		}
+		return nil
+	})
+}
+
+// readFontInfo returns the fonts in the font or collection file at path.
+func readFontInfo(path string) ([]FontInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	collection, err := sfnt.ParseCollectionReaderAt(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse font %s: %v", path, err)
 	}
 
-	for _, p := range candidates {
-		if _, err := os.Stat(p); err == nil {
-			return p
+	var buf sfnt.Buffer
+	var infos []FontInfo
+	for i := 0; i < collection.NumFonts(); i++ {
+		fnt, err := collection.Font(i)
+		if err != nil {
+			continue
 		}
+		info := FontInfo{Path: path, Index: i}
+		// Typographic names group the weights of a family under one name.
+		if info.Family, err = fnt.Name(&buf, sfnt.NameIDTypographicFamily); err != nil || info.Family == "" {
+			info.Family, _ = fnt.Name(&buf, sfnt.NameIDFamily)
+		}
+		if info.Style, err = fnt.Name(&buf, sfnt.NameIDTypographicSubfamily); err != nil || info.Style == "" {
+			info.Style, _ = fnt.Name(&buf, sfnt.NameIDSubfamily)
+		}
+		if info.Family == "" {
+			info.Family = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		}
+		infos = append(infos, info)
 	}
-	return ""
+	if len(infos) == 0 {
+		return nil, fmt.Errorf("no usable font in %s", path)
+	}
+	return infos, nil
+}
+
+// systemFontDirs returns the font directories of the system: those in the
+// fontconfig configuration and the usual system and user ones.
+func systemFontDirs() []string {
+	home, _ := os.UserHomeDir()
+	dirs := fontconfigDirs(home)
+	switch runtime.GOOS {
+	case "darwin":
+		dirs = append(dirs, "/System/Library/Fonts", "/Library/Fonts", filepath.Join(home, "Library/Fonts"))
+	case "windows":
+		dirs = append(dirs, filepath.Join(os.Getenv("WINDIR"), "Fonts"),
+			filepath.Join(os.Getenv("LOCALAPPDATA"), "Microsoft/Windows/Fonts"))
+	default:
+		dirs = append(dirs, "/usr/share/fonts", "/usr/local/share/fonts",
+			filepath.Join(xdgDataHome(home), "fonts"), filepath.Join(home, ".fonts"))
+	}
+
+	var existing []string
+	for _, dir := range dirs {
+		dir = filepath.Clean(dir)
+		if info, err := os.Stat(dir); err == nil && info.IsDir() && !slices.Contains(existing, dir) {
+			existing = append(existing, dir)
+		}
+	}
+	// Configured directories often lie inside the default ones, which would
+	// index their fonts twice.
+	var unique []string
+	for _, dir := range existing {
+		if !slices.ContainsFunc(existing, func(d string) bool { return d != dir && withinDir(dir, d) }) {
+			unique = append(unique, dir)
+		}
+	}
+	return unique
+}
+
+// withinDir reports whether path is dir or inside it.
+func withinDir(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// fontconfigDirs returns the <dir> entries of the fontconfig configuration,
+// $FONTCONFIG_FILE or /etc/fonts/fonts.conf, and of /etc/fonts/local.conf.
+func fontconfigDirs(home string) []string {
+	files := []string{"/etc/fonts/fonts.conf", "/etc/fonts/local.conf"}
+	if f := os.Getenv("FONTCONFIG_FILE"); f != "" {
+		files[0] = f
+	}
+
+	var dirs []string
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			continue
+		}
+		dec := xml.NewDecoder(f)
+		for {
+			tok, err := dec.Token()
+			if err != nil {
+				break
+			}
+			start, ok := tok.(xml.StartElement)
+			if !ok || start.Name.Local != "dir" {
+				continue
+			}
+			var dir string
+			if dec.DecodeElement(&dir, &start) != nil {
+				continue
+			}
+			dir = strings.TrimSpace(dir)
+			for _, attr := range start.Attr {
+				if attr.Name.Local == "prefix" && attr.Value == "xdg" {
+					dir = filepath.Join(xdgDataHome(home), dir)
+				}
+			}
+			if rest, ok := strings.CutPrefix(dir, "~"); ok {
+				dir = home + rest
+			}
+			if filepath.IsAbs(dir) {
+				dirs = append(dirs, dir)
+			}
+		}
+		f.Close()
+	}
+	return dirs
+}
+
+func xdgDataHome(home string) string {
+	if d := os.Getenv("XDG_DATA_HOME"); d != "" {
+		return d
+	}
+	return filepath.Join(home, ".local/share")
+}
+
+// find returns the index of the entry of family, preferring regular styles
+// and exact names over names that start with family.
+func (c *fontCatalog) find(family string) (int, bool) {
+	key := fontKey(family)
+	best, bestScore := -1, 0
+	for i, e := range c.entries {
+		name := fontKey(e.Family)
+		file := fontKey(strings.TrimSuffix(filepath.Base(e.Path), filepath.Ext(e.Path)))
+		score := 0
+		switch {
+		case name == key || file == key:
+			score = 2
+		case strings.HasPrefix(name, key):
+			score = 1
+		default:
+			continue
+		}
+		score *= 2
+		if regularStyle(e.Style) {
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	return best, best >= 0
+}
+
+func regularStyle(style string) bool {
+	switch strings.ToLower(style) {
+	case "regular", "normal", "book", "roman", "":
+		return true
+	}
+	return false
+}
+
+// fontKey normalizes a font name for matching: lowercase without spaces,
+// dashes and underscores.
+func fontKey(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '_':
+			return -1
+		}
+		return r
+	}, strings.ToLower(name))
+}
+
+// load returns the parsed font of entry i. Font files stay open for the
+// glyphs that are read as they are drawn.
+func (c *fontCatalog) load(i int) (*sfnt.Font, error) {
+	e := c.entries[i]
+	if fnt, ok := c.loaded[e]; ok {
+		return fnt, nil
+	}
+	f, err := os.Open(e.Path)
+	if err != nil {
+		return nil, err
+	}
+	collection, err := sfnt.ParseCollectionReaderAt(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to parse font %s: %v", e.Path, err)
+	}
+	fnt, err := collection.Font(e.Index)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to parse font %s: %v", e.Path, err)
+	}
+	c.loaded[e] = fnt
+	return fnt, nil
+}
+
+// fallback returns the index of the entry that covers r: a preferred font
+// if one does, else any installed font.
+func (c *fontCatalog) fallback(r rune) int {
+	if i, ok := c.fallbacks[r]; ok {
+		return i
+	}
+	var candidates []int
+	for _, family := range preferredFonts {
+		if i, ok := c.find(family); ok {
+			candidates = append(candidates, i)
+		}
+	}
+	for i := range c.entries {
+		candidates = append(candidates, i)
+	}
+
+	var buf sfnt.Buffer
+	found := -1
+	for _, i := range candidates {
+		if fnt, err := c.load(i); err == nil && hasGlyph(fnt, &buf, r) {
+			found = i
+			break
+		}
+	}
+	c.fallbacks[r] = found
+	return found
+}
+
+func hasGlyph(fnt *sfnt.Font, buf *sfnt.Buffer, r rune) bool {
+	x, err := fnt.GlyphIndex(buf, r)
+	return err == nil && x != 0
+}
+
+// SubtitleFont returns the font that subtitles in family are drawn with,
+// before fallbacks for glyphs it lacks.
+func SubtitleFont(family string) (FontInfo, bool) {
+	fonts.mu.Lock()
+	defer fonts.mu.Unlock()
+
+	fonts.scan()
+	i, ok := fonts.primary(family)
+	if !ok {
+		return FontInfo{}, false
+	}
+	return fonts.entries[i], true
+}
+
+// primary returns the index of the entry of family, or of the best available
+// font if family is empty or not installed.
+func (c *fontCatalog) primary(family string) (int, bool) {
+	if family != "" {
+		if i, ok := c.find(family); ok {
+			return i, true
+		}
+	}
+	for _, f := range preferredFonts {
+		if i, ok := c.find(f); ok {
+			return i, true
+		}
+	}
+	return 0, len(c.entries) > 0
+}
+
+// newSubtitleFace returns a face of the given size for drawing text in the
+// font family, or in the best available font if family is empty or not
+// installed. Glyphs the font lacks are taken from fallback fonts.
+func newSubtitleFace(family string, size float64, text string) (font.Face, error) {
+	fonts.mu.Lock()
+	defer fonts.mu.Unlock()
+
+	fonts.scan()
+
+	if family != "" {
+		if _, ok := fonts.find(family); !ok {
+			fmt.Printf("Warning: Font %q not found, using the default font\n", family)
+		}
+	}
+	primary, ok := fonts.primary(family)
+	if !ok {
+		return nil, fmt.Errorf("no suitable font found for subtitles")
+	}
+
+	face := &fallbackFace{}
+	entries := []int{primary}
+	if err := face.add(fonts.load(primary)); err != nil {
+		return nil, err
+	}
+	var missing []rune
+	for _, r := range text {
+		if unicode.IsSpace(r) || unicode.IsControl(r) || face.has(r) {
+			continue
+		}
+		i := fonts.fallback(r)
+		if i < 0 {
+			missing = append(missing, r)
+			continue
+		}
+		if !slices.Contains(entries, i) {
+			entries = append(entries, i)
+			face.add(fonts.load(i))
+		}
+	}
+	if len(missing) > 0 {
+		fmt.Printf("Warning: No installed font has the characters %q\n", string(missing))
+	}
+
+	for _, fnt := range face.fonts {
+		f, err := opentype.NewFace(fnt, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+		if err != nil {
+			return nil, err
+		}
+		face.faces = append(face.faces, f)
+	}
+	return face, nil
+}
+
+// fallbackFace is a font.Face that draws each glyph in the first of its
+// fonts that has it. Metrics are those of the first font.
+type fallbackFace struct {
+	fonts []*sfnt.Font
+	faces []font.Face
+	buf   sfnt.Buffer
+}
+
+func (f *fallbackFace) add(fnt *sfnt.Font, err error) error {
+	if err == nil {
+		f.fonts = append(f.fonts, fnt)
+	}
+	return err
+}
+
+func (f *fallbackFace) has(r rune) bool {
+	return slices.ContainsFunc(f.fonts, func(fnt *sfnt.Font) bool { return hasGlyph(fnt, &f.buf, r) })
+}
+
+func (f *fallbackFace) pick(r rune) font.Face {
+	for i, fnt := range f.fonts {
+		if hasGlyph(fnt, &f.buf, r) {
+			return f.faces[i]
+		}
+	}
+	return f.faces[0]
+}
+
+func (f *fallbackFace) Close() error {
+	for _, face := range f.faces {
+		face.Close()
+	}
+	return nil
+}
+
+func (f *fallbackFace) Glyph(dot fixed.Point26_6, r rune) (image.Rectangle, image.Image, image.Point, fixed.Int26_6, bool) {
+	return f.pick(r).Glyph(dot, r)
+}
+
+func (f *fallbackFace) GlyphBounds(r rune) (fixed.Rectangle26_6, fixed.Int26_6, bool) {
+	return f.pick(r).GlyphBounds(r)
+}
+
+func (f *fallbackFace) GlyphAdvance(r rune) (fixed.Int26_6, bool) {
+	return f.pick(r).GlyphAdvance(r)
+}
+
+func (f *fallbackFace) Kern(r0, r1 rune) fixed.Int26_6 {
+	if face := f.pick(r0); face == f.pick(r1) {
+		return face.Kern(r0, r1)
+	}
+	return 0
+}
+
+func (f *fallbackFace) Metrics() font.Metrics {
+	return f.faces[0].Metrics()
 }
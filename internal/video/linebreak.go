@@ -0,0 +1,244 @@
+package video
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/image/font"
+)
+
+// breakClass is the line breaking class of a character, a subset of those of
+// Unicode Standard Annex #14 that is enough for Chinese, Japanese, Korean
+// and Latin text.
+type breakClass int
+
+const (
+	classAL breakClass = iota // Letters, symbols and anything not listed
+	classNU                   // Digits
+	classID                   // Ideographs, kana, Hangul and emoji, which break on both sides
+	classSP                   // Spaces
+	classOP                   // Opening brackets and quotes, never at the end of a line
+	classCL                   // Closing brackets and CJK punctuation, never at the start of a line
+	classCP                   // Closing ASCII brackets, which also stick to the word after them
+	classEX                   // Exclamation and question marks
+	classIS                   // Latin separators within numbers and after words: , . : ;
+	classNS                   // Small kana, prolonged sound and iteration marks
+	classIN                   // Ellipses, which are not split
+	classHY                   // Hyphen-minus, break after
+	classBA                   // Dashes and other characters to break after
+	classQU                   // Quotes that can open or close
+	classGL                   // No-break spaces and joiners
+	classZW                   // Zero width space, break after
+	classCM                   // Combining marks, part of the character before
+)
+
+// lineClass returns the line breaking class of r.
+func lineClass(r rune) breakClass {
+	switch r {
+	case ' ', '\t':
+		return classSP
+	case '\u00A0', '\u202F', '\u2007', '\u2060', '\uFEFF':
+		return classGL
+	case '\u200B':
+		return classZW
+	case '\u200D':
+		return classCM
+	case '(', '[', '{', '（', '［', '｛', '〈', '《', '「', '『', '【', '〔', '〖', '〘', '〚', '｟', '“', '‘', '｢':
+		return classOP
+	case ')', ']', '}':
+		return classCP
+	case '、', '。', '，', '．', '､', '｡', '）', '］', '｝', '〉', '》', '」', '』', '】', '〕', '〗', '〙', '〛', '｠', '”', '’', '｣', '：', '；':
+		return classCL
+	case '!', '?', '！', '？', '‼', '⁇', '⁈', '⁉':
+		return classEX
+	case ',', '.', ':', ';':
+		return classIS
+	case 'ぁ', 'ぃ', 'ぅ', 'ぇ', 'ぉ', 'っ', 'ゃ', 'ゅ', 'ょ', 'ゎ', 'ゕ', 'ゖ',
+		'ァ', 'ィ', 'ゥ', 'ェ', 'ォ', 'ッ', 'ャ', 'ュ', 'ョ', 'ヮ', 'ヵ', 'ヶ',
+		'ー', 'ゝ', 'ゞ', 'ヽ', 'ヾ', '々', '〻', '・', '゠', '〜', '～', '％', '‰', '℃':
+		return classNS
+	case '…', '‥':
+		return classIN
+	case '-':
+		return classHY
+	case '‐', '–', '—', '\u3000', '|':
+		return classBA
+	case '"', '\'', '«', '»':
+		return classQU
+	}
+	switch {
+	case r >= '0' && r <= '9':
+		return classNU
+	case unicode.In(r, unicode.Mn, unicode.Me):
+		return classCM
+	case r >= 'ㇰ' && r <= 'ㇿ': // Small katakana for Ainu
+		return classNS
+	case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul, unicode.Yi),
+		r >= 0x3000 && r <= 0x303F,                // CJK symbols and punctuation
+		r >= 0xFF01 && r <= 0xFF60,                // Fullwidth forms
+		r >= 0x1F000 && unicode.Is(unicode.So, r): // Emoji
+		return classID
+	case unicode.IsDigit(r):
+		return classNU
+	}
+	return classAL
+}
+
+// canBreak reports whether a line may break before next. prev is the class of
+// the last character before it that is not a space, and spaced whether spaces
+// come in between.
+func canBreak(prev, next breakClass, spaced bool) bool {
+	switch {
+	case prev == classZW && !spaced:
+		return true
+	case next == classSP, next == classCM, next == classGL, prev == classGL && !spaced:
+		return false
+	// Kinsoku: closing punctuation and nonstarters never start a line, and
+	// opening punctuation never ends one, spaces or not.
+	case next == classCL, next == classCP, next == classEX, next == classIS, next == classNS:
+		return false
+	case prev == classOP:
+		return false
+	case spaced:
+		return true
+	}
+
+	word := func(c breakClass) bool { return c == classAL || c == classNU }
+	switch {
+	case prev == classQU, next == classQU:
+		return false
+	case next == classIN && prev == classIN, next == classIN && word(prev):
+		return false
+	case next == classHY, next == classBA:
+		return false
+	case prev == classHY && next == classNU:
+		return false
+	case word(prev) && word(next):
+		return false
+	case prev == classIS && word(next):
+		return false
+	case prev == classCP && word(next):
+		return false
+	case word(prev) && next == classOP:
+		return false
+	}
+	return true
+}
+
+// lineSegments splits a paragraph at its break opportunities, so that lines
+// may only break between the segments. Spaces stay at the end of the
+// segment before them.
+func lineSegments(text string) []string {
+	var segments []string
+	start := 0
+	prev, spaced := classSP, false
+	for i, r := range text {
+		c := lineClass(r)
+		if i > 0 && canBreak(prev, c, spaced) {
+			segments = append(segments, text[start:i])
+			start = i
+		}
+		switch c {
+		case classSP:
+			spaced = true
+		case classCM:
+			// Combining marks take the class of their base character.
+		default:
+			prev, spaced = c, false
+		}
+	}
+	if start < len(text) {
+		segments = append(segments, text[start:])
+	}
+	return segments
+}
+
+// wrapText breaks text into lines no wider than maxWidth at the break
+// opportunities of its words and CJK characters. Each paragraph is broken
+// into as few lines as greedy filling would give, of about equal length.
+func wrapText(face font.Face, text string, maxWidth int) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		var segments []string
+		for _, s := range lineSegments(strings.TrimSpace(paragraph)) {
+			if measureStringWidth(face, strings.TrimRight(s, " \t")) <= maxWidth {
+				segments = append(segments, s)
+				continue
+			}
+			// A word longer than a line is broken between its characters.
+			segments = append(segments, splitCharacters(s)...)
+		}
+		if len(segments) == 0 {
+			continue
+		}
+
+		widths := make([]int, len(segments))
+		trimmed := make([]int, len(segments))
+		for i, s := range segments {
+			widths[i] = measureStringWidth(face, s)
+			trimmed[i] = measureStringWidth(face, strings.TrimRight(s, " \t"))
+		}
+
+		starts := balanceLines(widths, trimmed, maxWidth)
+		for i, start := range starts {
+			end := len(segments)
+			if i+1 < len(starts) {
+				end = starts[i+1]
+			}
+			lines = append(lines, strings.TrimRight(strings.Join(segments[start:end], ""), " \t"))
+		}
+	}
+	return lines
+}
+
+// splitCharacters splits s into its characters, keeping combining marks with
+// the character before them.
+func splitCharacters(s string) []string {
+	var parts []string
+	start := 0
+	for i, r := range s {
+		if i > 0 && lineClass(r) != classCM {
+			parts = append(parts, s[start:i])
+			start = i
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// fillLines fills segments of the given widths greedily into lines of the
+// given width and returns the index of the first segment of every line.
+// trimmed are the widths without trailing spaces, which may hang over the
+// end of a line.
+func fillLines(widths, trimmed []int, width int) []int {
+	starts := []int{0}
+	current := 0
+	for i := range widths {
+		if i > starts[len(starts)-1] && current+trimmed[i] > width {
+			starts = append(starts, i)
+			current = 0
+		}
+		current += widths[i]
+	}
+	return starts
+}
+
+// balanceLines breaks segments into as many lines as filling lines of
+// maxWidth would, but as narrow as possible, which evens out their lengths.
+func balanceLines(widths, trimmed []int, maxWidth int) []int {
+	starts := fillLines(widths, trimmed, maxWidth)
+	if len(starts) < 2 {
+		return starts
+	}
+	// Narrower lines never need fewer lines, so the narrowest width that
+	// keeps the count can be found by bisection.
+	lo, hi := 1, maxWidth
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if len(fillLines(widths, trimmed, mid)) <= len(starts) {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	return fillLines(widths, trimmed, lo)
+}
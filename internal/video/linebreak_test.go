@@ -0,0 +1,139 @@
+package video
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// testFace is a fixed-width face on which ASCII characters are 10 pixels
+// wide and everything else 20, like half- and full-width characters.
+type testFace struct {
+	font.Face
+}
+
+func (testFace) GlyphAdvance(r rune) (fixed.Int26_6, bool) {
+	if r < 0x80 {
+		return fixed.I(10), true
+	}
+	return fixed.I(20), true
+}
+
+var (
+	latinWord = regexp.MustCompile(`[A-Za-z]+`)
+	// Characters that must never start or end a line.
+	noStart = "，。！？」）"
+	noEnd   = "「（《"
+)
+
+func TestWrapText(t *testing.T) {
+	face := testFace{basicfont.Face7x13}
+	tests := []struct {
+		name     string
+		text     string
+		maxWidth int
+		lines    int
+		// tolerance is how much shorter than the longest line any line but
+		// the last may be.
+		tolerance int
+	}{
+		{"chinese", "今天我们来介绍一下新版本的主要功能，包括字幕样式、翻译和导出。", 200, 4, 40},
+		{"mixed", "我们使用 FFmpeg 和 LibreOffice 把 PowerPoint 演示文稿转换成视频，支持 HLS 导出！", 240, 4, 100},
+		{"english", "The quick brown fox jumps over the lazy dog while the presenter keeps talking.", 200, 5, 40},
+		{"quotes", "他说：「这个功能（字幕翻译）非常好用。」然后打开了《用户手册》继续讲解。", 160, 5, 40},
+		{"punctuation", "第一点，第二点。第三点！第四点？第五点」第六点）第七点，第八点。", 100, 8, 0},
+		{"brackets", "请参考「配置说明」和（部署指南）以及《常见问题》中的内容", 120, 5, 40},
+		// Punctuation right where a full line would end.
+		{"closing at break", "一二三四五，六七八九十。甲乙丙丁戊！己庚辛壬癸？", 100, 5, 20},
+		{"quote at break", "一二三四五」六七八九十）甲乙丙丁戊", 100, 4, 20},
+		{"opening at break", "一二三四「五六七八九（十甲乙丙丁《戊己", 100, 4, 20},
+		{"mixed at break", "使用 Go 语言开发，「PPT」转视频", 140, 3, 40},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := wrapText(face, tt.text, tt.maxWidth)
+			if len(lines) != tt.lines {
+				t.Fatalf("got %d lines %q, want %d", len(lines), lines, tt.lines)
+			}
+			if got, want := strings.Join(lines, ""), strings.ReplaceAll(tt.text, " ", ""); strings.ReplaceAll(got, " ", "") != want {
+				t.Errorf("lines %q do not add up to the text", lines)
+			}
+
+			for i, line := range lines {
+				if w := measureStringWidth(face, line); w > tt.maxWidth {
+					t.Errorf("line %q is %d wide, more than %d", line, w, tt.maxWidth)
+				}
+				runes := []rune(line)
+				if i > 0 && strings.ContainsRune(noStart, runes[0]) {
+					t.Errorf("line %q starts with %q", line, runes[0])
+				}
+				if i < len(lines)-1 && strings.ContainsRune(noEnd, runes[len(runes)-1]) {
+					t.Errorf("line %q ends with %q", line, runes[len(runes)-1])
+				}
+			}
+
+			for _, word := range latinWord.FindAllString(tt.text, -1) {
+				found := false
+				for _, line := range lines {
+					if latinWordAt(line, word) {
+						found = true
+						break
+					}
+				}
+				if !found {
+					t.Errorf("word %q is split across lines %q", word, lines)
+				}
+			}
+
+			// Balanced lines are about equally long; only the last may be
+			// left short.
+			longest, shortest := 0, tt.maxWidth
+			for i, line := range lines {
+				w := measureStringWidth(face, line)
+				longest = max(longest, w)
+				if i < len(lines)-1 {
+					shortest = min(shortest, w)
+				}
+			}
+			if longest-shortest > tt.tolerance {
+				t.Errorf("lines %q differ by %d pixels", lines, longest-shortest)
+			}
+		})
+	}
+}
+
+// latinWordAt reports whether word appears in line as a whole word.
+func latinWordAt(line, word string) bool {
+	for _, w := range latinWord.FindAllString(line, -1) {
+		if w == word {
+			return true
+		}
+	}
+	return false
+}
+
+func TestWrapTextLongWord(t *testing.T) {
+	face := testFace{basicfont.Face7x13}
+	lines := wrapText(face, "Supercalifragilisticexpialidocious", 100)
+	for _, line := range lines {
+		if w := measureStringWidth(face, line); w > 100 {
+			t.Errorf("line %q is %d wide, more than 100", line, w)
+		}
+	}
+	if got := strings.Join(lines, ""); got != "Supercalifragilisticexpialidocious" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestWrapTextParagraphs(t *testing.T) {
+	face := testFace{basicfont.Face7x13}
+	lines := wrapText(face, "第一行\r\n\nsecond line", 400)
+	want := []string{"第一行", "second line"}
+	if strings.Join(lines, "|") != strings.Join(want, "|") {
+		t.Errorf("got %q, want %q", lines, want)
+	}
+}
@@ -0,0 +1,139 @@
+package video
+
+import (
+	"fmt"
+	"math"
+)
+
+// Motion effects for still slides.
+const (
+	MotionNone     = "none"
+	MotionZoomIn   = "zoom_in"
+	MotionZoomOut  = "zoom_out"
+	MotionPanLeft  = "pan_left"
+	MotionPanRight = "pan_right"
+	MotionFocus    = "focus" // Zoom towards Region
+	// MotionCallout zooms towards Region, holds and zooms back out, within
+	// a single segment.
+	MotionCallout = "callout"
+)
+
+// calloutRamp is how long a callout takes to zoom in or out, in seconds.
+const calloutRamp = 0.6
+
+// defaultMotionStrength is how far zoom and pan effects zoom in.
+const defaultMotionStrength = 0.15
+
+// maxFocusZoom keeps small focus regions from being blown up into blur.
+const maxFocusZoom = 3.0
+
+// Region is a rectangle in coordinates normalized to the slide, with 0,0 at
+// the top left and 1,1 at the bottom right.
+type Region struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	W float64 `json:"w"`
+	H float64 `json:"h"`
+}
+
+// Valid reports whether r is a non-empty region within the slide.
+func (r Region) Valid() bool {
+	return r.W > 0 && r.H > 0 && r.X >= 0 && r.Y >= 0 && r.X+r.W <= 1.0001 && r.Y+r.H <= 1.0001
+}
+
+// Motion is a slow camera move over a slide for as long as the slide is
+// shown, also known as the Ken Burns effect.
+type Motion struct {
+	Effect   string  `json:"effect"`
+	Strength float64 `json:"strength,omitempty"` // Zoom added by the effect, default 0.15
+	Region   *Region `json:"region,omitempty"`   // Target of MotionFocus
+}
+
+// active reports whether m moves the camera at all.
+func (m *Motion) active() bool {
+	if m == nil {
+		return false
+	}
+	switch m.Effect {
+	case MotionZoomIn, MotionZoomOut, MotionPanLeft, MotionPanRight:
+		return true
+	case MotionFocus, MotionCallout:
+		return m.Region != nil && m.Region.Valid()
+	}
+	return false
+}
+
+// callout reports whether m is a callout, which is never merged with the
+// motion of neighboring segments.
+func (m *Motion) callout() bool {
+	return m != nil && m.Effect == MotionCallout
+}
+
+// shot is a timeline entry as the camera sees it. Consecutive entries that
+// show the same slide form a run, split by callouts, over which the motion of
+// the slide continues.
+type shot struct {
+	Frames int     // Of the entry
+	Start  int     // Frames of the run before the entry
+	Run    int     // Frames of the whole run
+	Motion *Motion // Of the slide, or a callout
+	Base   *Motion // Of the slide under a callout
+}
+
+// zoompan returns a zoompan filter that applies the motion of s at fps and
+// outputs width x height. The input should be larger than the output so that
+// sub-pixel movement stays smooth.
+func (s shot) zoompan(fps int, width, height int) string {
+	// Eased progress from 0 to 1 over the run.
+	run := fmt.Sprintf("((1-cos(PI*min((on+%d)/%d\\,1)))/2)", s.Start, max(s.Run-1, 1))
+
+	zoom, cx, cy := s.Motion.camera(run)
+	if s.Motion.Effect == MotionCallout {
+		// Progress up to 1 and back down to 0 at the end of the entry, from
+		// the camera of the slide towards the region and back.
+		ramp := max(min(int(calloutRamp*float64(fps)), (s.Frames-1)/3), 1)
+		p := fmt.Sprintf("((1-cos(PI*min(min(on\\,%d-on)/%d\\,1)))/2)", s.Frames-1, ramp)
+		baseZoom, baseX, baseY := "1", "iw/2", "ih/2"
+		if s.Base.active() {
+			baseZoom, baseX, baseY = s.Base.camera(run)
+		}
+		r := s.Motion.Region
+		end := math.Min(maxFocusZoom, math.Max(1, math.Min(1/r.W, 1/r.H)))
+		zoom = fmt.Sprintf("(%s)+(%g-(%s))*%s", baseZoom, end, baseZoom, p)
+		cx = fmt.Sprintf("(%s)+(iw*%g-(%s))*%s", baseX, r.X+r.W/2, baseX, p)
+		cy = fmt.Sprintf("(%s)+(ih*%g-(%s))*%s", baseY, r.Y+r.H/2, baseY, p)
+	}
+
+	// x and y are the top left corner of the view.
+	x := fmt.Sprintf("max(0\\,min(%s-iw/zoom/2\\,iw-iw/zoom))", cx)
+	y := fmt.Sprintf("max(0\\,min(%s-ih/zoom/2\\,ih-ih/zoom))", cy)
+	return fmt.Sprintf("zoompan=z=%s:x=%s:y=%s:d=1:s=%dx%d:fps=%d", zoom, x, y, width, height, fps)
+}
+
+// camera returns the zoom of m and the point of the slide at the center of
+// the view, in input pixels, at progress p, an expression from 0 to 1.
+func (m *Motion) camera(p string) (zoom, cx, cy string) {
+	strength := m.Strength
+	if strength <= 0 {
+		strength = defaultMotionStrength
+	}
+
+	zoom, cx, cy = fmt.Sprintf("1+%g", strength), "iw/2", "ih/2"
+	switch m.Effect {
+	case MotionZoomIn:
+		zoom = fmt.Sprintf("1+%g*%s", strength, p)
+	case MotionZoomOut:
+		zoom = fmt.Sprintf("1+%g*(1-%s)", strength, p)
+	case MotionPanLeft:
+		cx = fmt.Sprintf("iw/zoom/2+(iw-iw/zoom)*(1-%s)", p)
+	case MotionPanRight:
+		cx = fmt.Sprintf("iw/zoom/2+(iw-iw/zoom)*%s", p)
+	case MotionFocus:
+		r := m.Region
+		end := math.Min(maxFocusZoom, math.Max(1, math.Min(1/r.W, 1/r.H)))
+		zoom = fmt.Sprintf("1+%g*%s", end-1, p)
+		cx = fmt.Sprintf("iw*(0.5%+g*%s)", r.X+r.W/2-0.5, p)
+		cy = fmt.Sprintf("ih*(0.5%+g*%s)", r.Y+r.H/2-0.5, p)
+	}
+	return zoom, cx, cy
+}
@@ -0,0 +1,31 @@
+package video
+
+import (
+	"fmt"
+	"math"
+)
+
+// musicGraph returns a filter graph that mixes the looped music track under
+// the narration and labels the result out. The track is cut to total seconds,
+// faded in and out, and, unless disabled, sidechain-compressed by the
+// narration so it ducks while someone is speaking.
+func musicGraph(narration string, track string, out string, total float64, music MusicOptions) string {
+	fadeOutStart := math.Max(0, total-music.FadeOut)
+	bg := fmt.Sprintf("%saresample=48000,aformat=channel_layouts=stereo,volume=%.3f,atrim=0:%.3f", track, music.Volume, total)
+	if music.FadeIn > 0 {
+		bg += fmt.Sprintf(",afade=t=in:st=0:d=%.3f", music.FadeIn)
+	}
+	if music.FadeOut > 0 {
+		bg += fmt.Sprintf(",afade=t=out:st=%.3f:d=%.3f", fadeOutStart, music.FadeOut)
+	}
+
+	if music.NoDucking {
+		return bg + "[bg];" +
+			narration + "aresample=48000,aformat=channel_layouts=stereo[narr];" +
+			"[narr][bg]amix=inputs=2:duration=first:dropout_transition=0:normalize=0" + out
+	}
+	return bg + "[bg];" +
+		narration + "aresample=48000,aformat=channel_layouts=stereo,asplit=2[narr][key];" +
+		"[bg][key]sidechaincompress=threshold=0.02:ratio=10:attack=20:release=600[ducked];" +
+		"[narr][ducked]amix=inputs=2:duration=first:dropout_transition=0:normalize=0" + out
+}
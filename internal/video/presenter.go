@@ -0,0 +1,126 @@
+package video
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Presenter is a recording of the speaker shown picture-in-picture over the
+// slides. Its sound is not used; upload narration for that.
+type Presenter struct {
+	Path     string
+	Position string  // Default PositionBottomRight
+	Width    float64 // Width relative to the video width, default 0.25
+	Margin   int     // Distance from the edges in pixels, default 24
+	// Radius rounds the corners, relative to the short side of the picture;
+	// 0.5 makes a circle or pill.
+	Radius float64
+	// ChromaKey is a color such as "0x00FF00" or "green" made transparent,
+	// for recordings in front of a green screen. Similarity is how close a
+	// color must be to be keyed out, default 0.3.
+	ChromaKey  string
+	Similarity float64
+}
+
+// colorPattern matches the color syntaxes of ffmpeg that are safe to put
+// into a filter graph.
+var colorPattern = regexp.MustCompile(`^(0x|#)?[0-9A-Fa-f]{6}$|^[A-Za-z]+$`)
+
+// Validate reports whether the settings of p are usable.
+func (p *Presenter) Validate() error {
+	if !ValidPosition(p.Position) {
+		return fmt.Errorf("unknown presenter position %q", p.Position)
+	}
+	if p.ChromaKey != "" && !colorPattern.MatchString(p.ChromaKey) {
+		return fmt.Errorf("invalid chroma key color %q", p.ChromaKey)
+	}
+	if p.Radius < 0 || p.Radius > 0.5 {
+		return fmt.Errorf("presenter radius must be between 0 and 0.5")
+	}
+	return nil
+}
+
+// presenterRun is a stretch of the video during which one presenter
+// recording is shown.
+type presenterRun struct {
+	Presenter *Presenter
+	Start     float64
+	Duration  float64
+	// Continuous runs show the recording at the time of the video, as for a
+	// recording of the whole talk. Others start it from the beginning.
+	Continuous bool
+}
+
+// presenterRuns returns the runs of presenters over segments of the given
+// durations. Per-segment presenters replace the deck presenter, which keeps
+// running in the background.
+func presenterRuns(opts RenderOptions, durations []float64) []presenterRun {
+	var runs []presenterRun
+	elapsed := 0.0
+	extends := false // Whether the last run reaches up to the current segment
+	for i, d := range durations {
+		p, continuous := opts.Presenter, true
+		if i < len(opts.Presenters) && opts.Presenters[i] != nil {
+			p, continuous = opts.Presenters[i], false
+		}
+		if p != nil {
+			if n := len(runs); extends && runs[n-1].Presenter.Path == p.Path && runs[n-1].Continuous == continuous {
+				runs[n-1].Duration += d
+			} else {
+				runs = append(runs, presenterRun{Presenter: p, Start: elapsed, Duration: d, Continuous: continuous})
+			}
+		}
+		extends = p != nil
+		elapsed += d
+	}
+	return runs
+}
+
+// filter returns the filters that turn the recording into the picture shown
+// during r on a video of the given size.
+func (r presenterRun) filter(input int, width, fps int) string {
+	p := r.Presenter
+	end := r.Start + r.Duration
+
+	var f []string
+	if r.Continuous {
+		f = append(f, fmt.Sprintf("trim=start=%.3f:end=%.3f", r.Start, end))
+	} else {
+		f = append(f, fmt.Sprintf("trim=duration=%.3f,setpts=PTS-STARTPTS+%.3f/TB", r.Duration, r.Start))
+	}
+	f = append(f, fmt.Sprintf("fps=%d", fps))
+
+	rel := p.Width
+	if rel <= 0 || rel > 1 {
+		rel = 0.25
+	}
+	f = append(f, fmt.Sprintf("scale=%d:-2", max(int(float64(width)*rel), 2)))
+
+	if p.ChromaKey != "" {
+		similarity := p.Similarity
+		if similarity <= 0 || similarity > 1 {
+			similarity = 0.3
+		}
+		f = append(f, fmt.Sprintf("format=yuva420p,chromakey=%s:%.3f:0.08", p.ChromaKey, similarity))
+	}
+
+	f = append(f, "format=rgba")
+	if p.Radius > 0 {
+		// Alpha is 0 outside the rounded corners: where the distance past the
+		// straight part of both edges is greater than the radius.
+		radius := fmt.Sprintf("(min(W\\,H)*%g)", p.Radius)
+		dx := fmt.Sprintf("max(abs(X-W/2)-(W/2-%s)\\,0)", radius)
+		dy := fmt.Sprintf("max(abs(Y-H/2)-(H/2-%s)\\,0)", radius)
+		f = append(f, fmt.Sprintf("geq=r=r(X\\,Y):g=g(X\\,Y):b=b(X\\,Y):a=if(gt(hypot(%s\\,%s)\\,%s)\\,0\\,alpha(X\\,Y))", dx, dy, radius))
+	}
+	return fmt.Sprintf("[%d:v]%s", input, strings.Join(f, ","))
+}
+
+// overlay returns the overlay filter that shows the picture of r, labeled
+// pip, over the stream labeled base.
+func (r presenterRun) overlay(base, pip string) string {
+	x, y := cornerPosition(r.Presenter.Position, PositionBottomRight, r.Presenter.Margin)
+	return fmt.Sprintf("%s%soverlay=%s:%s:eof_action=pass:enable=between(t\\,%.3f\\,%.3f)",
+		base, pip, x, y, r.Start, r.Start+r.Duration)
+}
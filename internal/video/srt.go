@@ -0,0 +1,65 @@
+package video
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// Cue is a subtitle shown from Start to End, in seconds from the start of
+// the video.
+type Cue struct {
+	Start float64
+	End   float64
+	Text  string
+}
+
+// SubtitleTrack is an SRT file muxed into the video as a subtitle stream
+// that players can turn on.
+type SubtitleTrack struct {
+	Path     string
+	Language string // Code such as "en" or "eng"
+}
+
+// WriteSRT writes cues as an SRT file.
+func WriteSRT(path string, cues []Cue) error {
+	var b strings.Builder
+	n := 0
+	for _, c := range cues {
+		text := strings.TrimSpace(c.Text)
+		if text == "" {
+			continue
+		}
+		n++
+		fmt.Fprintf(&b, "%d\n%s --> %s\n%s\n\n", n, srtTime(c.Start), srtTime(c.End), text)
+	}
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
+
+// srtTime formats seconds as an SRT timestamp, e.g. 00:01:02,345.
+func srtTime(seconds float64) string {
+	ms := int64(math.Round(seconds * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d,%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// iso639 maps two-letter language codes to the three-letter ones that MP4
+// files tag streams with.
+var iso639 = map[string]string{
+	"ar": "ara", "de": "deu", "en": "eng", "es": "spa", "fr": "fra", "hi": "hin", "id": "ind", "it": "ita",
+	"ja": "jpn", "ko": "kor", "ms": "msa", "nl": "nld", "pl": "pol", "pt": "por", "ru": "rus", "th": "tha",
+	"tr": "tur", "uk": "ukr", "vi": "vie", "zh": "zho",
+}
+
+// trackLanguage returns the three-letter code of a language code such as
+// "en" or "zh-TW", or "" if it is not known.
+func trackLanguage(code string) string {
+	code = strings.ToLower(code)
+	if base, _, ok := strings.Cut(code, "-"); ok {
+		code = base
+	}
+	if len(code) == 3 {
+		return code
+	}
+	return iso639[code]
+}
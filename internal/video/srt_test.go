@@ -0,0 +1,49 @@
+package video
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSRTTime(t *testing.T) {
+	tests := []struct {
+		seconds float64
+		want    string
+	}{
+		{0, "00:00:00,000"},
+		{1.5, "00:00:01,500"},
+		{62.345, "00:01:02,345"},
+		{59.9996, "00:01:00,000"},
+		{3600, "01:00:00,000"},
+		{3725.0004, "01:02:05,000"},
+		{36000 + 0.001, "10:00:00,001"},
+	}
+	for _, tt := range tests {
+		if got := srtTime(tt.seconds); got != tt.want {
+			t.Errorf("srtTime(%v) = %s, want %s", tt.seconds, got, tt.want)
+		}
+	}
+}
+
+func TestWriteSRT(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "subtitles.srt")
+	cues := []Cue{
+		{Start: 0, End: 2.5, Text: "Hello"},
+		{Start: 2.5, End: 3, Text: "  "},
+		{Start: 3, End: 5.25, Text: "你好\nHello again\n"},
+	}
+	if err := WriteSRT(path, cues); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Empty cues are left out without a gap in the numbering.
+	want := "1\n00:00:00,000 --> 00:00:02,500\nHello\n\n" +
+		"2\n00:00:03,000 --> 00:00:05,250\n你好\nHello again\n\n"
+	if string(data) != want {
+		t.Errorf("got\n%s\nwant\n%s", data, want)
+	}
+}
@@ -0,0 +1,165 @@
+package video
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/LeonRhapsody/pptTovideo/internal/config"
+	"golang.org/x/image/font"
+)
+
+// Subtitle alignments.
+const (
+	AlignCenter = "center"
+	AlignLeft   = "left"
+	AlignRight  = "right"
+)
+
+// subtitleStyle is a config.SubtitleStyle with its defaults filled in and
+// its colors parsed.
+type subtitleStyle struct {
+	config.SubtitleStyle
+	color, outline color.NRGBA
+	background     *color.NRGBA
+}
+
+// ValidateSubtitleStyle reports whether the settings of s are usable.
+func ValidateSubtitleStyle(s config.SubtitleStyle) error {
+	_, err := resolveSubtitleStyle(s)
+	return err
+}
+
+func resolveSubtitleStyle(s config.SubtitleStyle) (subtitleStyle, error) {
+	style := subtitleStyle{SubtitleStyle: s}
+	if style.FontSize <= 0 {
+		style.FontSize = 48
+	}
+	if style.LineHeight <= 0 {
+		style.LineHeight = 1.5
+	}
+	if style.MarginV <= 0 {
+		style.MarginV = 50
+	}
+	if style.OutlineWidth < 0 || style.OutlineWidth > 20 {
+		return style, fmt.Errorf("subtitle outline width must be between 0 and 20")
+	}
+	if style.MaxLines < 0 {
+		return style, fmt.Errorf("subtitle max lines must not be negative")
+	}
+
+	switch style.Position {
+	case "":
+		style.Position = config.SubtitleBottom
+	case config.SubtitleBottom, config.SubtitleTop:
+	case config.SubtitleCustom:
+		if style.Y < 0 || style.Y > 1 {
+			return style, fmt.Errorf("custom subtitle position must be between 0 and 1")
+		}
+	default:
+		return style, fmt.Errorf("unknown subtitle position %q", style.Position)
+	}
+
+	switch style.Align {
+	case "":
+		style.Align = AlignCenter
+	case AlignCenter, AlignLeft, AlignRight:
+	default:
+		return style, fmt.Errorf("unknown subtitle alignment %q", style.Align)
+	}
+
+	var err error
+	if style.color, err = parseColor(style.Color, color.NRGBA{255, 255, 255, 255}); err != nil {
+		return style, err
+	}
+	if style.outline, err = parseColor(style.OutlineColor, color.NRGBA{0, 0, 0, 255}); err != nil {
+		return style, err
+	}
+	if style.Background != "" {
+		bg, err := parseColor(style.Background, color.NRGBA{})
+		if err != nil {
+			return style, err
+		}
+		style.background = &bg
+	}
+	return style, nil
+}
+
+// parseColor parses a "#RRGGBB" or "#RRGGBBAA" color, returning fallback
+// for "".
+func parseColor(s string, fallback color.NRGBA) (color.NRGBA, error) {
+	if s == "" {
+		return fallback, nil
+	}
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if len(hex) != 8 || err != nil {
+		return fallback, fmt.Errorf("invalid color %q", s)
+	}
+	return color.NRGBA{uint8(v >> 24), uint8(v >> 16), uint8(v >> 8), uint8(v)}, nil
+}
+
+// top returns the top edge of a block of subtitles of the given height on an
+// image of height h.
+func (s subtitleStyle) top(blockHeight, h int) int {
+	switch s.Position {
+	case config.SubtitleTop:
+		return s.MarginV
+	case config.SubtitleCustom:
+		return int(s.Y*float64(h)) - blockHeight/2
+	}
+	return h - s.MarginV - blockHeight
+}
+
+// limitLines cuts lines down to the max lines of s, ending the last one with
+// an ellipsis that fits into maxWidth.
+func (s subtitleStyle) limitLines(face font.Face, lines []string, maxWidth int) []string {
+	if s.MaxLines == 0 || len(lines) <= s.MaxLines {
+		return lines
+	}
+	lines = lines[:s.MaxLines]
+	last := []rune(lines[len(lines)-1])
+	for len(last) > 0 && measureStringWidth(face, string(last)+"…") > maxWidth {
+		last = last[:len(last)-1]
+	}
+	lines[len(lines)-1] = string(last) + "…"
+	return lines
+}
+
+// dilate grows the shapes of mask within r by radius pixels in every
+// direction, which gives the mask of an outline around them.
+func dilate(mask *image.Alpha, r image.Rectangle, radius int) *image.Alpha {
+	// rows[w] is the mask grown by w pixels horizontally.
+	rows := make([]*image.Alpha, radius+1)
+	rows[0] = mask
+	for w := 1; w <= radius; w++ {
+		prev, next := rows[w-1], image.NewAlpha(r)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				a := max(prev.AlphaAt(x-1, y).A, prev.AlphaAt(x, y).A, prev.AlphaAt(x+1, y).A)
+				next.SetAlpha(x, y, color.Alpha{a})
+			}
+		}
+		rows[w] = next
+	}
+
+	// Each row within the radius contributes the width of the circle there.
+	out := image.NewAlpha(r)
+	for dy := -radius; dy <= radius; dy++ {
+		row := rows[int(math.Sqrt(float64(radius*radius-dy*dy)))]
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				if a := row.AlphaAt(x, y+dy).A; a > out.AlphaAt(x, y).A {
+					out.SetAlpha(x, y, color.Alpha{a})
+				}
+			}
+		}
+	}
+	return out
+}
@@ -2,135 +2,457 @@ package video
 
 import (
 	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
 
-	ffmpeg "github.com/u2takey/ffmpeg-go"
+	"github.com/LeonRhapsody/pptTovideo/internal/audio"
+	"github.com/LeonRhapsody/pptTovideo/internal/config"
 )
 
 type RenderOptions struct {
 	EnableSubtitles bool
-	FontSize        int
-	VideoQuality    string // "720p", "1080p", "4k"
+	SubtitleStyle   config.SubtitleStyle
+
+	// Encoding sets the resolution, frame rate and encoder settings.
+	Encoding config.EncodingProfile
+
+	// Music is mixed under the narration of the final video when set.
+	Music *MusicOptions
+
+	// Motion moves the camera over every slide; Motions overrides it per
+	// segment, parallel to the images. A slide shown for several segments
+	// moves continuously with the motion of its first segment.
+	Motion  *Motion
+	Motions []*Motion
+
+	// Canvas sets the frame of the video and how slides are laid out on it.
+	// Subtitles are placed on the canvas, not the slide.
+	Canvas *Canvas
+
+	// Branding adds intro and outro clips, a watermark and fades.
+	Branding *Branding
+
+	// Presenter is shown picture-in-picture over all segments, in sync with
+	// the video; Presenters overrides it per segment, parallel to the images,
+	// with recordings that start when their segments do.
+	Presenter  *Presenter
+	Presenters []*Presenter
+
+	// Callouts point out a region of the slide during single segments,
+	// parallel to the images. A zoom callout takes the camera from the motion
+	// of its slide to the region and back, after which the motion carries on
+	// where it would have been; highlights assume the camera is still.
+	Callouts []*Callout
+
+	// SubtitleTracks are muxed into the output as soft subtitles, in
+	// addition to the subtitles drawn onto the video.
+	SubtitleTracks []SubtitleTrack
+
+	// Chapters are muxed into the output as chapter markers. Their times
+	// refer to the finished video, branding clips included.
+	Chapters []Chapter
+}
+
+// callout returns the callout of segment i, if it has one.
+func (o RenderOptions) callout(i int) *Callout {
+	if i < len(o.Callouts) {
+		return o.Callouts[i]
+	}
+	return nil
+}
+
+// motion returns the motion of segment i.
+func (o RenderOptions) motion(i int) *Motion {
+	if i < len(o.Motions) && o.Motions[i] != nil {
+		return o.Motions[i]
+	}
+	return o.Motion
+}
+
+// MusicOptions describes a background track that loops for the length of the
+// video and is ducked whenever narration plays.
+type MusicOptions struct {
+	Path    string
+	Volume  float64 // Linear gain applied to the track, e.g. 0.3
+	FadeIn  float64 // Seconds
+	FadeOut float64 // Seconds
+	// NoDucking keeps the music at a constant level under narration.
+	NoDucking bool
 }
 
-// ComposeVideo creates a video from corresponding images and audios with subtitles.
+// ComposeVideo creates a video from corresponding images and audios with
+// subtitles in a single ffmpeg encode. The audios are joined into one
+// narration track, and the images and subtitles become timelines whose entries
+// last exactly as long as the corresponding audio, so that the video stays in
+// sync with the narration over any number of segments.
 func ComposeVideo(images []string, audios []string, texts []string, output string, opts RenderOptions) error {
 	if len(images) != len(audios) {
 		return fmt.Errorf("number of images (%d) and audios (%d) do not match", len(images), len(audios))
 	}
+	if len(images) == 0 {
+		return fmt.Errorf("nothing to compose")
+	}
 
-	tempDir := filepath.Dir(output)
-	var videoParts []string
-	var tempImages []string
+	tempDir, err := os.MkdirTemp(filepath.Dir(output), "compose-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
 
-	for i, img := range images {
-		audio := audios[i]
-		text := ""
-		if i < len(texts) {
-			text = texts[i]
+	// 1. Narration: one continuous track, measured per segment.
+	durations := make([]float64, len(audios))
+	total := 0.0
+	for i, a := range audios {
+		if durations[i], err = audio.FileDuration(a); err != nil {
+			return fmt.Errorf("failed to get duration of segment %d: %w", i, err)
 		}
+		total += durations[i]
+	}
+	narration := filepath.Join(tempDir, "narration.wav")
+	if err := audio.Concat(audios, narration); err != nil {
+		return fmt.Errorf("failed to join narration: %w", err)
+	}
+
+	// 2. Canvas: the requested one, or else the first slide's size scaled to
+	// the resolution of the profile.
+	var width, height int
+	if opts.Canvas != nil {
+		width, height, err = opts.Canvas.dimensions(opts.Encoding.Resolution)
+	} else {
+		width, height, err = imageSize(images[0])
+		width, height = scaleToResolution(width, height, opts.Encoding.Resolution)
+	}
+	if err != nil {
+		return err
+	}
+	fps := frameRate(opts.Encoding)
 
-		currentImg := img
-		if opts.EnableSubtitles && text != "" {
-			burnedImgPath := filepath.Join(tempDir, fmt.Sprintf("burned_%d.jpg", i))
-			err := DrawSubtitle(img, burnedImgPath, text, opts.FontSize)
+	// 3. Timelines. Consecutive segments showing the same image or subtitle
+	// are merged into one entry.
+	var slides, subtitles timeline
+	var highlights []highlightRun
+	sizes := make(map[string]image.Point)
+	slideSize := func(img string) (image.Point, error) {
+		size, ok := sizes[img]
+		if !ok {
+			var err error
+			if size.X, size.Y, err = imageSize(img); err != nil {
+				return size, err
+			}
+			sizes[img] = size
+		}
+		return size, nil
+	}
+	elapsed := 0.0
+	for i, img := range images {
+		motion := opts.motion(i)
+		if motion.active() && motion.Region != nil {
+			size, err := slideSize(img)
 			if err != nil {
-				fmt.Printf("Warning: Failed to draw subtitle for slide %d: %v\n", i, err)
+				return err
+			}
+			motion = opts.Canvas.placeMotion(motion, size.X, size.Y, width, height)
+		}
+		if c := opts.callout(i); c != nil {
+			switch c.Effect {
+			case CalloutZoom:
+				size, err := slideSize(img)
+				if err != nil {
+					return err
+				}
+				motion = opts.Canvas.placeMotion(&Motion{Effect: MotionCallout, Region: &c.Region}, size.X, size.Y, width, height)
+			case CalloutHighlight:
+				size, err := slideSize(img)
+				if err != nil {
+					return err
+				}
+				path := filepath.Join(tempDir, fmt.Sprintf("highlight_%d.png", i))
+				rect := opts.Canvas.place(c.Region, size.X, size.Y, width, height)
+				if err := DrawHighlightOverlay(path, width, height, rect); err != nil {
+					return err
+				}
+				highlights = append(highlights, highlightRun{Path: path, Start: elapsed, Duration: durations[i]})
+			}
+		}
+		slides.add(img, durations[i], motion)
+		elapsed += durations[i]
+	}
+
+	if opts.EnableSubtitles {
+		// Segments without text show a transparent frame.
+		blank := filepath.Join(tempDir, "subtitle_blank.png")
+		if err := DrawSubtitleOverlay(blank, width, height, "", opts.SubtitleStyle); err != nil {
+			return err
+		}
+		for i := range images {
+			path := blank
+			if i < len(texts) && texts[i] != "" {
+				path = filepath.Join(tempDir, fmt.Sprintf("subtitle_%d.png", i))
+				if err := DrawSubtitleOverlay(path, width, height, texts[i], opts.SubtitleStyle); err != nil {
+					fmt.Printf("Warning: Failed to draw subtitle for slide %d: %v\n", i, err)
+					path = blank
+				}
+			}
+			subtitles.add(path, durations[i], nil)
+		}
+	}
+
+	// 4. One encode.
+	args := []string{"-hide_banner", "-nostdin"}
+	var filter string
+	input := 0
+
+	if slides.moving() {
+		// Camera moves need every slide as a stream of its own.
+		var labels strings.Builder
+		for k, shot := range slides.shots(fps) {
+			args = append(args, "-loop", "1", "-framerate", strconv.Itoa(fps),
+				"-t", strconv.FormatFloat(float64(shot.Frames+1)/float64(fps), 'f', 3, 64), "-i", slides.entries[k].Path)
+			if shot.Motion.active() {
+				// Zoom from twice the output size to keep the movement smooth.
+				filter += fmt.Sprintf("%s,setsar=1,%s", opts.Canvas.fit(fmt.Sprintf("[%d:v]", input), fmt.Sprintf("l%d", k), 2*width, 2*height), shot.zoompan(fps, width, height))
 			} else {
-				currentImg = burnedImgPath
-				tempImages = append(tempImages, burnedImgPath)
+				filter += opts.Canvas.fit(fmt.Sprintf("[%d:v]", input), fmt.Sprintf("l%d", k), width, height) + ",setsar=1"
 			}
+			filter += fmt.Sprintf(",trim=end_frame=%d,setpts=PTS-STARTPTS[s%d];", shot.Frames, k)
+			fmt.Fprintf(&labels, "[s%d]", k)
+			input++
+		}
+		filter += fmt.Sprintf("%sconcat=n=%d:v=1:a=0", labels.String(), len(slides.entries))
+	} else {
+		slideList := filepath.Join(tempDir, "slides.ffconcat")
+		if err := slides.write(slideList); err != nil {
+			return err
 		}
+		args = append(args, "-f", "concat", "-safe", "0", "-i", slideList)
+		filter = fmt.Sprintf("%s,setsar=1,fps=%d", opts.Canvas.fit("[0:v]", "l", width, height), fps)
+		input++
+	}
 
-		partPath := filepath.Join(tempDir, fmt.Sprintf("part_%d.mp4", i))
+	// Highlights go over the slides, beneath the presenter.
+	for k, run := range highlights {
+		args = append(args, "-loop", "1", "-framerate", strconv.Itoa(fps),
+			"-t", strconv.FormatFloat(run.Duration, 'f', 3, 64), "-i", run.Path)
+		filter += fmt.Sprintf("[hlbase%d];%s[hl%d];", k, run.filter(input), k)
+		filter += run.overlay(fmt.Sprintf("[hlbase%d]", k), fmt.Sprintf("[hl%d]", k))
+		input++
+	}
 
-		// FIX: Get exact audio duration to ensure perfect sync
-		dur, err := getDuration(audio)
-		if err != nil {
-			fmt.Printf("Warning: Failed to get duration for %s: %v\n", audio, err)
-			dur = 5.0 // Fallback
-		}
-
-		// Standard simple filter: pad ensures even dimensions
-		vf := "pad=ceil(iw/2)*2:ceil(ih/2)*2"
-
-		// Use -t for explicit duration matching
-		input1 := ffmpeg.Input(currentImg, ffmpeg.KwArgs{"loop": 1, "t": dur})
-		input2 := ffmpeg.Input(audio)
-
-		// Dynamic bitrate based on quality
-		bitrate := "5M"
-		switch strings.ToLower(opts.VideoQuality) {
-		case "720p":
-			bitrate = "2.5M"
-		case "4k":
-			bitrate = "15M"
-		default:
-			bitrate = "5M"
-		}
-
-		err = ffmpeg.Output([]*ffmpeg.Stream{input1, input2}, partPath, ffmpeg.KwArgs{
-			"c:v":     "libx264",
-			"tune":    "stillimage",
-			"c:a":     "aac",
-			"b:v":     bitrate,
-			"b:a":     "192k",
-			"pix_fmt": "yuv420p",
-			"vf":      vf,
-		}).
-			OverWriteOutput().
-			Run()
+	// Presenter recordings go between the slides and the subtitles.
+	presenters := presenterRuns(opts, durations)
+	for k, run := range presenters {
+		args = append(args, "-i", run.Presenter.Path)
+		filter += fmt.Sprintf("[pipbase%d];%s[pip%d];", k, run.filter(input, width, fps), k)
+		filter += run.overlay(fmt.Sprintf("[pipbase%d]", k), fmt.Sprintf("[pip%d]", k))
+		input++
+	}
+
+	if opts.EnableSubtitles {
+		subtitleList := filepath.Join(tempDir, "subtitles.ffconcat")
+		if err := subtitles.write(subtitleList); err != nil {
+			return err
+		}
+		args = append(args, "-f", "concat", "-safe", "0", "-i", subtitleList)
+		filter += fmt.Sprintf("[base];[%d:v]fps=%d,format=rgba[subs];[base][subs]overlay=0:0:eof_action=pass", input, fps)
+		input++
+	}
+	filter += ",format=yuv420p[vout]"
+	videoOut := "[vout]"
+
+	args = append(args, "-i", narration)
+	narrationInput := input
+	input++
+
+	narrationLabel := fmt.Sprintf("[%d:a]", narrationInput)
+	audioOut := narrationLabel
+	if opts.Music != nil {
+		args = append(args, "-stream_loop", "-1", "-i", opts.Music.Path)
+		filter += ";" + musicGraph(audioOut, fmt.Sprintf("[%d:a]", input), "[aout]", total, *opts.Music)
+		audioOut = "[aout]"
+		input++
+	}
 
+	if opts.Branding != nil {
+		branded, err := opts.Branding.brand(videoOut, audioOut, total, input, width, height, fps)
 		if err != nil {
-			return fmt.Errorf("failed to create part %d: %v", i, err)
+			return err
 		}
-		videoParts = append(videoParts, partPath)
+		args = append(args, branded.inputs...)
+		filter += branded.filter
+		videoOut, audioOut = branded.video, branded.audio
+		total = branded.duration
 	}
 
-	// 2. Concatenate all parts
-	concatListPath := filepath.Join(tempDir, "concat_list.txt")
-	file, err := os.Create(concatListPath)
+	var chapterArgs []string
+	if len(opts.Chapters) > 0 {
+		metadata := filepath.Join(tempDir, "chapters.txt")
+		if err := writeFFMetadata(metadata, opts.Chapters); err != nil {
+			return err
+		}
+		args = append(args, "-f", "ffmetadata", "-i", metadata)
+		chapterArgs = []string{"-map_chapters", strconv.Itoa(input)}
+		input++
+	}
+
+	var trackArgs []string
+	for k, track := range opts.SubtitleTracks {
+		args = append(args, "-i", track.Path)
+		trackArgs = append(trackArgs, "-map", fmt.Sprintf("%d:s", input))
+		if lang := trackLanguage(track.Language); lang != "" {
+			trackArgs = append(trackArgs, fmt.Sprintf("-metadata:s:s:%d", k), "language="+lang)
+		}
+		input++
+	}
+	if len(trackArgs) > 0 {
+		trackArgs = append(trackArgs, "-c:s", "mov_text")
+	}
+
+	args = append(args,
+		"-filter_complex", filter,
+		"-map", videoOut, "-map", audioOut)
+	if audioOut == narrationLabel {
+		// Streams of inputs are mapped without brackets.
+		args[len(args)-1] = fmt.Sprintf("%d:a", narrationInput)
+	}
+	args = append(args, trackArgs...)
+	args = append(args, chapterArgs...)
+	still := !slides.moving() && len(presenters) == 0 &&
+		(opts.Branding == nil || opts.Branding.Intro == "" && opts.Branding.Outro == "")
+	encoder, err := encoderArgs(opts.Encoding, total, still)
 	if err != nil {
 		return err
 	}
+	args = append(args, encoder...)
+	args = append(args,
+		"-t", strconv.FormatFloat(total, 'f', 3, 64),
+		"-y", output,
+	)
 
-	for _, part := range videoParts {
-		file.WriteString(fmt.Sprintf("file '%s'\n", filepath.Base(part)))
+	cmd := exec.Command("ffmpeg", args...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("ffmpeg failed: %w, output: %s", err, string(out))
 	}
-	file.Close()
+	return nil
+}
 
-	err = ffmpeg.Input(concatListPath, ffmpeg.KwArgs{"f": "concat", "safe": 0}).
-		Output(output, ffmpeg.KwArgs{"c": "copy"}).
-		OverWriteOutput().
-		Run()
+// fitCanvas scales the input to fit width x height and pads the rest.
+func fitCanvas(width, height int) string {
+	return fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2", width, height, width, height)
+}
+
+func imageSize(path string) (int, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
 
+	cfg, _, err := image.DecodeConfig(f)
 	if err != nil {
-		return fmt.Errorf("failed to concat videos: %w", err)
+		return 0, 0, fmt.Errorf("failed to read image %s: %w", path, err)
 	}
+	return cfg.Width, cfg.Height, nil
+}
+
+// timeline is a sequence of still images with durations, written as a script
+// for ffmpeg's concat demuxer.
+type timeline struct {
+	entries []timelineEntry
+}
+
+type timelineEntry struct {
+	Path     string
+	Duration float64
+	Motion   *Motion
+}
 
-	// Cleanup
-	for _, part := range videoParts {
-		os.Remove(part)
+// add appends path for duration seconds, extending the last entry if it shows
+// the same file. An extended entry keeps its motion. Callouts always get an
+// entry of their own.
+func (t *timeline) add(path string, duration float64, motion *Motion) {
+	if n := len(t.entries); n > 0 && t.entries[n-1].Path == path && !motion.callout() && !t.entries[n-1].Motion.callout() {
+		t.entries[n-1].Duration += duration
+		return
 	}
-	for _, tmp := range tempImages {
-		os.Remove(tmp)
+	t.entries = append(t.entries, timelineEntry{Path: path, Duration: duration, Motion: motion})
+}
+
+// shots returns the frames and camera motion of every entry at fps. Entries
+// end on the frame nearest to their end time rather than lasting a rounded
+// length, so that rounding errors do not add up over the deck. Consecutive
+// entries of the same image, split by callouts, form a run that moves
+// continuously with the motion of its first entry that is not a callout.
+func (t *timeline) shots(fps int) []shot {
+	shots := make([]shot, len(t.entries))
+	elapsed, frame := 0.0, 0
+	for k, e := range t.entries {
+		elapsed += e.Duration
+		end := int(math.Round(elapsed * float64(fps)))
+		shots[k] = shot{Frames: max(end-frame, 1), Motion: e.Motion}
+		frame = end
 	}
-	os.Remove(concatListPath)
 
-	return nil
+	for first := 0; first < len(shots); {
+		last := first
+		for last+1 < len(shots) && t.entries[last+1].Path == t.entries[first].Path {
+			last++
+		}
+		var motion *Motion
+		found, run := false, 0
+		for k := first; k <= last; k++ {
+			if !found && !shots[k].Motion.callout() {
+				motion, found = shots[k].Motion, true
+			}
+			run += shots[k].Frames
+		}
+		start := 0
+		for k := first; k <= last; k++ {
+			shots[k].Start, shots[k].Run = start, run
+			if shots[k].Motion.callout() && shots[k].Motion.active() {
+				shots[k].Base = motion
+			} else {
+				// Callouts the canvas cuts off leave the slide moving.
+				shots[k].Motion = motion
+			}
+			start += shots[k].Frames
+		}
+		first = last + 1
+	}
+	return shots
 }
 
-func getDuration(path string) (float64, error) {
-	cmd := exec.Command("ffprobe", "-v", "error", "-show_entries", "format=duration", "-of", "default=noprint_wrappers=1:nokey=1", path)
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		return 0, err
+// moving reports whether any entry has an active motion.
+func (t *timeline) moving() bool {
+	for _, e := range t.entries {
+		if e.Motion.active() {
+			return true
+		}
 	}
+	return false
+}
 
-	val := strings.TrimSpace(string(out))
-	return strconv.ParseFloat(val, 64)
+func (t *timeline) write(path string) error {
+	var b strings.Builder
+	b.WriteString("ffconcat version 1.0\n")
+	for _, e := range t.entries {
+		abs, err := filepath.Abs(e.Path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&b, "file '%s'\nduration %s\n", strings.ReplaceAll(abs, "'", `'\''`), strconv.FormatFloat(e.Duration, 'f', 6, 64))
+	}
+	// The demuxer ignores the duration of the last entry unless the file is
+	// listed once more.
+	if n := len(t.entries); n > 0 {
+		abs, _ := filepath.Abs(t.entries[n-1].Path)
+		fmt.Fprintf(&b, "file '%s'\n", strings.ReplaceAll(abs, "'", `'\''`))
+	}
+	return os.WriteFile(path, []byte(b.String()), 0644)
 }
@@ -0,0 +1,220 @@
+package voices
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Prefix marks voice names that refer to a stored reference rather than to a
+// voice known by the engine, e.g. "ref:marketing/3f0c...".
+const Prefix = "ref:"
+
+// DefaultWorkspace holds the references of clients that name no workspace.
+const DefaultWorkspace = "default"
+
+const metaFile = "meta.json"
+
+var workspacePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ValidWorkspace reports whether name can be used as a workspace.
+func ValidWorkspace(name string) bool {
+	return workspacePattern.MatchString(name)
+}
+
+// Reference is an uploaded sample of a voice together with its transcript.
+// Engines that support zero-shot cloning receive both inline.
+type Reference struct {
+	ID         string    `json:"id"`
+	Workspace  string    `json:"workspace"`
+	Name       string    `json:"name"`
+	Transcript string    `json:"transcript"`
+	AudioFile  string    `json:"audio_file"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// VoiceName returns the name under which the reference is selected.
+func (r *Reference) VoiceName() string {
+	return Prefix + r.Workspace + "/" + r.ID
+}
+
+// ParseVoiceName returns the workspace and ID of the reference a voice name
+// refers to. Names without a workspace, from before references were kept per
+// workspace, are in DefaultWorkspace.
+func ParseVoiceName(name string) (workspace, id string, ok bool) {
+	rest, ok := strings.CutPrefix(name, Prefix)
+	if !ok {
+		return "", "", false
+	}
+	if workspace, id, found := strings.Cut(rest, "/"); found {
+		return workspace, id, true
+	}
+	return DefaultWorkspace, rest, true
+}
+
+// Store keeps references on disk per workspace: a directory per workspace
+// holding one directory per reference with the audio sample and a metadata
+// file.
+type Store struct {
+	Dir string
+	mu  sync.Mutex
+}
+
+// NewStore returns the store of dir, moving references saved before there
+// were workspaces into place. Create one at startup and share it, as its lock
+// only serializes the users of that store.
+func NewStore(dir string) *Store {
+	s := &Store{Dir: dir}
+	s.migrate()
+	return s
+}
+
+// migrate moves references stored directly in Dir, before there were
+// workspaces, into DefaultWorkspace.
+func (s *Store) migrate() {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if _, err := uuid.Parse(e.Name()); err != nil || !e.IsDir() {
+			continue
+		}
+		dst := filepath.Join(s.Dir, DefaultWorkspace, e.Name())
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err == nil {
+			err = os.Rename(filepath.Join(s.Dir, e.Name()), dst)
+		}
+		if err != nil {
+			fmt.Printf("Warning: Failed to move voice reference %s to the default workspace: %v\n", e.Name(), err)
+		}
+	}
+}
+
+// List returns the references of a workspace, newest first.
+func (s *Store) List(workspace string) ([]Reference, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !ValidWorkspace(workspace) {
+		return nil, fmt.Errorf("invalid workspace %q", workspace)
+	}
+	entries, err := os.ReadDir(filepath.Join(s.Dir, workspace))
+	if os.IsNotExist(err) {
+		return []Reference{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	refs := []Reference{}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		ref, err := s.load(workspace, e.Name())
+		if err != nil {
+			fmt.Printf("Warning: skipping voice reference %s: %v\n", e.Name(), err)
+			continue
+		}
+		refs = append(refs, *ref)
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		return refs[i].CreatedAt.After(refs[j].CreatedAt)
+	})
+	return refs, nil
+}
+
+// Get returns the reference with the given ID in a workspace.
+func (s *Store) Get(workspace, id string) (*Reference, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load(workspace, id)
+}
+
+// Add stores a new reference in a workspace. ext is the extension of the
+// audio sample, including the dot.
+func (s *Store) Add(workspace string, name string, transcript string, audio io.Reader, ext string) (*Reference, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !ValidWorkspace(workspace) {
+		return nil, fmt.Errorf("invalid workspace %q", workspace)
+	}
+	ref := &Reference{
+		ID:         uuid.New().String(),
+		Workspace:  workspace,
+		Name:       name,
+		Transcript: transcript,
+		AudioFile:  "reference" + ext,
+		CreatedAt:  time.Now(),
+	}
+
+	dir := filepath.Join(s.Dir, workspace, ref.ID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	out, err := os.Create(filepath.Join(dir, ref.AudioFile))
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	_, err = io.Copy(out, audio)
+	out.Close()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	data, _ := json.MarshalIndent(ref, "", "  ")
+	if err := os.WriteFile(filepath.Join(dir, metaFile), data, 0644); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return ref, nil
+}
+
+// Delete removes a reference of a workspace and its audio.
+func (s *Store) Delete(workspace, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.load(workspace, id); err != nil {
+		return err
+	}
+	return os.RemoveAll(filepath.Join(s.Dir, workspace, id))
+}
+
+// AudioPath returns the path of the reference's audio sample.
+func (s *Store) AudioPath(ref *Reference) string {
+	return filepath.Join(s.Dir, ref.Workspace, ref.ID, ref.AudioFile)
+}
+
+func (s *Store) load(workspace, id string) (*Reference, error) {
+	if !ValidWorkspace(workspace) {
+		return nil, fmt.Errorf("invalid workspace %q", workspace)
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("invalid voice reference id %q", id)
+	}
+	data, err := os.ReadFile(filepath.Join(s.Dir, workspace, id, metaFile))
+	if err != nil {
+		return nil, fmt.Errorf("voice reference %s not found in workspace %s", id, workspace)
+	}
+	var ref Reference
+	if err := json.Unmarshal(data, &ref); err != nil {
+		return nil, err
+	}
+	// Migrated references were written without their workspace.
+	ref.Workspace = workspace
+	return &ref, nil
+}